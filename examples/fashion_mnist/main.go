@@ -7,6 +7,7 @@ import (
 	"log"
 	"nn-go/nn"
 	"nn-go/nn/activations"
+	"nn-go/nn/callbacks"
 	"nn-go/nn/initializers"
 	"nn-go/nn/layers"
 	"nn-go/nn/loss"
@@ -93,9 +94,9 @@ func load() nn.TrainTestSet {
 
 func makeModel(imageSize int) *nn.Model {
 	model := nn.NewModel(
-		imageSize,
+		nn.Shape{imageSize},
 		&loss.CategoricalCrossEntropy{},
-		optimisers.NewAdamOptimizer(),
+		optimisers.NewAdamOptimizer(0.001, 0.9, 0.999, 1e-8, false),
 	)
 	wHe := initializers.He{}
 	bHe := initializers.NewConstInitializer(0.01)
@@ -121,6 +122,6 @@ func main() {
 			2,
 			4,
 			true,
-		),
+		).WithCallbacks(callbacks.NewProgressBar(os.Stdout)),
 	)
 }
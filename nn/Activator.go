@@ -1,3 +1,9 @@
 package nn
 
-type Activator func(float32) float32
+// Activator an element-wise activation function paired with its derivative
+type Activator interface {
+	Forward(x float32) float32
+	// Derivative of the activation at x, where y = Forward(x) so activations
+	// like Sigmoid and Tanh can reuse their output
+	Derivative(x float32, y float32) float32
+}
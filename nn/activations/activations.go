@@ -4,29 +4,234 @@ import (
 	math "github.com/chewxy/math32"
 )
 
-func ReLU(v float32) float32 {
-	return math.Max(0, v)
+// The SELU constants from Klambauer et al. 2017
+const (
+	seluAlpha = 1.6732632423543772848170429916717
+	seluScale = 1.0507009873554804934193349852946
+)
+
+var (
+	// ReLU max(0, x)
+	ReLU = relu{}
+	// Linear the identity, x
+	Linear = linear{}
+	// Sigmoid 1 / (1 + e^-x)
+	Sigmoid = sigmoid{}
+	// Tanh the hyperbolic tangent
+	Tanh = tanh{}
+	// LRelU a LeakyReLU with a slope of 0.01
+	LRelU = NewLeakyReLU(0.01)
+	// GELU x * Φ(x), where Φ is the standard normal CDF
+	GELU = gelu{}
+	// SiLU x * sigmoid(x), the same as Swish with beta 1
+	SiLU = NewSwish(1)
+	// SELU scale * ELU(x) with the self-normalizing alpha and scale
+	SELU = selu{}
+	// Softplus ln(1 + e^x)
+	Softplus = softplus{}
+	// Softsign x / (1 + |x|)
+	Softsign = softsign{}
+	// HardSigmoid a piecewise linear sigmoid, clip(x/6 + 1/2, 0, 1)
+	HardSigmoid = hardSigmoid{}
+	// HardSwish x * HardSigmoid(x)
+	HardSwish = hardSwish{}
+	// Mish x * tanh(softplus(x))
+	Mish = mish{}
+)
+
+type relu struct{}
+
+func (relu) Forward(x float32) float32 {
+	return math.Max(0, x)
+}
+
+func (relu) Derivative(x float32, _ float32) float32 {
+	if x > 0 {
+		return 1
+	}
+	return 0
+}
+
+type linear struct{}
+
+func (linear) Forward(x float32) float32 {
+	return x
+}
+
+func (linear) Derivative(float32, float32) float32 {
+	return 1
+}
+
+type sigmoid struct{}
+
+func (sigmoid) Forward(x float32) float32 {
+	return 1. / (1. + math.Exp(-x))
+}
+
+func (sigmoid) Derivative(_ float32, y float32) float32 {
+	return y * (1 - y)
+}
+
+type tanh struct{}
+
+func (tanh) Forward(x float32) float32 {
+	return math.Tanh(x)
+}
+
+func (tanh) Derivative(_ float32, y float32) float32 {
+	return 1 - y*y
+}
+
+// LeakyReLU x for positive x, otherwise slope * x
+type LeakyReLU struct {
+	slope float32
+}
+
+func NewLeakyReLU(slope float32) LeakyReLU {
+	return LeakyReLU{slope}
+}
+
+func (l LeakyReLU) Forward(x float32) float32 {
+	if x < 0 {
+		return l.slope * x
+	}
+	return x
 }
 
-func Linear(v float32) float32 {
-	return v
+func (l LeakyReLU) Derivative(x float32, _ float32) float32 {
+	if x < 0 {
+		return l.slope
+	}
+	return 1
+}
+
+// ELU x for positive x, otherwise alpha * (e^x - 1)
+type ELU struct {
+	alpha float32
+}
+
+func NewELU(alpha float32) ELU {
+	return ELU{alpha}
 }
 
-func Sigmoid(v float32) float32 {
-	return 1. / (1. + math.Exp(-v))
+func (e ELU) Forward(x float32) float32 {
+	if x > 0 {
+		return x
+	}
+	return e.alpha * (math.Exp(x) - 1)
 }
 
-func Softmax(v float32) float32 {
-	return 1 / math.Exp(v)
+func (e ELU) Derivative(x float32, y float32) float32 {
+	if x > 0 {
+		return 1
+	}
+	return y + e.alpha
 }
 
-func Tanh(v float32) float32 {
-	return math.Tanh(v)
+type selu struct{}
+
+func (selu) Forward(x float32) float32 {
+	if x > 0 {
+		return seluScale * x
+	}
+	return seluScale * seluAlpha * (math.Exp(x) - 1)
 }
 
-func LRelU(v float32) float32 {
-	if v < 0 {
-		return 0.01 * v
+func (selu) Derivative(x float32, _ float32) float32 {
+	if x > 0 {
+		return seluScale
 	}
-	return v
+	return seluScale * seluAlpha * math.Exp(x)
+}
+
+type gelu struct{}
+
+func (gelu) Forward(x float32) float32 {
+	return 0.5 * x * (1 + math.Erf(x/math.Sqrt2))
+}
+
+func (gelu) Derivative(x float32, _ float32) float32 {
+	cdf := 0.5 * (1 + math.Erf(x/math.Sqrt2))
+	pdf := math.Exp(-0.5*x*x) / math.Sqrt(2*math.Pi)
+	return cdf + x*pdf
+}
+
+// Swish x * sigmoid(beta * x)
+type Swish struct {
+	beta float32
+}
+
+func NewSwish(beta float32) Swish {
+	return Swish{beta}
+}
+
+func (s Swish) Forward(x float32) float32 {
+	return x * Sigmoid.Forward(s.beta*x)
+}
+
+func (s Swish) Derivative(x float32, y float32) float32 {
+	sig := Sigmoid.Forward(s.beta * x)
+	return sig + s.beta*y*(1-sig)
+}
+
+type softplus struct{}
+
+// Forward rearranged as max(x, 0) + ln(1 + e^-|x|) so large x doesn't overflow
+func (softplus) Forward(x float32) float32 {
+	return math.Max(x, 0) + math.Log1p(math.Exp(-math.Abs(x)))
+}
+
+func (softplus) Derivative(x float32, _ float32) float32 {
+	return Sigmoid.Forward(x)
+}
+
+type softsign struct{}
+
+func (softsign) Forward(x float32) float32 {
+	return x / (1 + math.Abs(x))
+}
+
+func (softsign) Derivative(x float32, _ float32) float32 {
+	d := 1 + math.Abs(x)
+	return 1 / (d * d)
+}
+
+type hardSigmoid struct{}
+
+func (hardSigmoid) Forward(x float32) float32 {
+	return math.Max(0, math.Min(1, x/6+0.5))
+}
+
+func (hardSigmoid) Derivative(x float32, _ float32) float32 {
+	if x > -3 && x < 3 {
+		return 1. / 6
+	}
+	return 0
+}
+
+type hardSwish struct{}
+
+func (hardSwish) Forward(x float32) float32 {
+	return x * HardSigmoid.Forward(x)
+}
+
+func (hardSwish) Derivative(x float32, _ float32) float32 {
+	switch {
+	case x <= -3:
+		return 0
+	case x >= 3:
+		return 1
+	}
+	return x/3 + 0.5
+}
+
+type mish struct{}
+
+func (mish) Forward(x float32) float32 {
+	return x * math.Tanh(Softplus.Forward(x))
+}
+
+func (mish) Derivative(x float32, _ float32) float32 {
+	t := math.Tanh(Softplus.Forward(x))
+	return t + x*(1-t*t)*Sigmoid.Forward(x)
 }
@@ -0,0 +1,158 @@
+package nn
+
+import "log"
+
+// averagedWeights a shadow copy of each parameter's value
+type averagedWeights map[*Param]*Matrix
+
+// swap the values of params with their shadows, in place so layers keep their params
+func (a averagedWeights) swap(params []*Param) {
+	for _, p := range params {
+		shadow, ok := a[p]
+		if !ok {
+			log.Fatalf("No averaged weights for param %s", p.Name)
+		}
+		p.Value.v, shadow.v = shadow.v, p.Value.v
+	}
+}
+
+// copyTo copy the shadow values into params, leaving the shadows as they are
+func (a averagedWeights) copyTo(params []*Param) {
+	for _, p := range params {
+		shadow, ok := a[p]
+		if !ok {
+			log.Fatalf("No averaged weights for param %s", p.Name)
+		}
+		for i, row := range shadow.v {
+			copy(p.Value.v[i], row)
+		}
+	}
+}
+
+// EMA keeps an exponential moving average of the weights, shadow = decay*shadow + (1-decay)*value,
+// updated after every weight update in Train. The averaged weights often evaluate better than the
+// last ones, Swap them in for evaluation and Swap again to go back to training
+type EMA struct {
+	decay   float32
+	shadow  averagedWeights
+	swapped bool
+}
+
+// NewEMA create a moving average with decay in [0, 1), typically 0.99 or 0.999
+func NewEMA(decay float32) *EMA {
+	if decay < 0 || decay >= 1 {
+		log.Fatalf("EMA decay must be in [0, 1), got %f", decay)
+	}
+	return &EMA{decay: decay, shadow: averagedWeights{}}
+}
+
+// Update the averages with the current values of params, a new param starts at its value
+func (e *EMA) Update(params []*Param) {
+	if e.swapped {
+		log.Fatal("Cannot update EMA while the averaged weights are swapped in")
+	}
+	for _, p := range params {
+		shadow, ok := e.shadow[p]
+		if !ok {
+			e.shadow[p] = p.Value.Copy()
+			continue
+		}
+		for i, row := range shadow.v {
+			for j := range row {
+				row[j] = e.decay*row[j] + (1-e.decay)*p.Value.v[i][j]
+			}
+		}
+	}
+}
+
+// Swap the averaged weights into params, or back out again
+func (e *EMA) Swap(params []*Param) {
+	e.shadow.swap(params)
+	e.swapped = !e.swapped
+}
+
+// Swapped whether the averaged weights are currently in the params
+func (e *EMA) Swapped() bool {
+	return e.swapped
+}
+
+// SWA Stochastic Weight Averaging, the equal average of the weights at the end of each of the last
+// epochs of training. Usually combined with a high constant or cyclical learning rate so the
+// weights explore around a wide minimum. Train copies the averaged weights into the model when it
+// finishes, then refreshes the statistics of any StatisticsLayer, since those of the last weights
+// don't match the averaged ones. Passing the same SWA to another Train carries on the average.
+// Callbacks still run after the copy, so one that restores other weights at the end of training,
+// like EarlyStopping with restoreBest, replaces the averaged weights
+type SWA struct {
+	epochs  int
+	count   int
+	average averagedWeights
+}
+
+// NewSWA average the weights over the last epochs of training
+func NewSWA(epochs int) *SWA {
+	if epochs < 1 {
+		log.Fatalf("SWA must average at least 1 epoch, got %d", epochs)
+	}
+	return &SWA{epochs: epochs, average: averagedWeights{}}
+}
+
+// update the running average with the current values of params
+func (s *SWA) update(params []*Param) {
+	s.count++
+	for _, p := range params {
+		average, ok := s.average[p]
+		if !ok {
+			s.average[p] = p.Value.Copy()
+			continue
+		}
+		for i, row := range average.v {
+			for j := range row {
+				row[j] += (p.Value.v[i][j] - row[j]) / float32(s.count)
+			}
+		}
+	}
+}
+
+// Count how many epochs have been averaged
+func (s *SWA) Count() int {
+	return s.count
+}
+
+// StatisticsLayer is implemented by layers that keep running statistics of their input for use at
+// inference, e.g. batch normalization. ResetStatistics clears them and UpdateStatistics adds a batch
+// of input to them
+type StatisticsLayer interface {
+	Layer
+	ResetStatistics()
+	UpdateStatistics(input *Matrix)
+}
+
+// RefreshStatistics recompute the statistics of every StatisticsLayer from instances, in batches of
+// batchSize, with the current weights
+func (m *Model) RefreshStatistics(instances *Matrix, batchSize int) {
+	var stateful bool
+	for _, l := range m.layers {
+		if s, ok := l.(StatisticsLayer); ok {
+			s.ResetStatistics()
+			stateful = true
+		}
+	}
+	if !stateful {
+		return
+	}
+	batches := (instances.rows + batchSize - 1) / batchSize
+	for b := 0; b < batches; b++ {
+		end := (b + 1) * batchSize
+		if end > instances.rows {
+			end = instances.rows
+		}
+		activations := instances.SliceRows(b*batchSize, end)
+		for _, l := range m.layers {
+			if s, ok := l.(StatisticsLayer); ok {
+				s.UpdateStatistics(activations)
+			}
+			activations = l.Forward(activations)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package nn
+
+// Logs the values of a batch or epoch passed to callbacks, by name. Batches have their size, loss and
+// metrics, and grad_norm when the weights were updated. Epochs have the mean batch loss and metrics,
+// val_loss and the validation metrics, lr and duration in seconds. Train begins with the number of
+// epochs and batches, and ends with the logs of the last epoch
+type Logs map[string]float32
+
+// Callback is told about each stage of Train, with the model being trained. Epochs and batches are
+// counted from 0. Call Model.StopTraining to end training early
+type Callback interface {
+	OnTrainBegin(m *Model, logs Logs)
+	OnTrainEnd(m *Model, logs Logs)
+	OnEpochBegin(m *Model, epoch int, logs Logs)
+	OnEpochEnd(m *Model, epoch int, logs Logs)
+	OnBatchBegin(m *Model, batch int, logs Logs)
+	OnBatchEnd(m *Model, batch int, logs Logs)
+}
+
+// BaseCallback ignores every stage, embed it in a Callback that only needs some of them
+type BaseCallback struct{}
+
+func (BaseCallback) OnTrainBegin(*Model, Logs)      {}
+func (BaseCallback) OnTrainEnd(*Model, Logs)        {}
+func (BaseCallback) OnEpochBegin(*Model, int, Logs) {}
+func (BaseCallback) OnEpochEnd(*Model, int, Logs)   {}
+func (BaseCallback) OnBatchBegin(*Model, int, Logs) {}
+func (BaseCallback) OnBatchEnd(*Model, int, Logs)   {}
@@ -0,0 +1,118 @@
+package callbacks
+
+import (
+	math "github.com/chewxy/math32"
+	"log"
+	"nn-go/nn"
+	"strings"
+)
+
+// Mode whether a monitored log improves by going down or up
+type Mode int
+
+const (
+	Auto Mode = iota // Max for logs whose name contains accuracy, otherwise Min
+	Min              // Lower is better, e.g. a loss
+	Max              // Higher is better, e.g. accuracy or AUC
+)
+
+// monitor tracks the best value of a log over epochs
+type monitor struct {
+	name     string
+	minDelta float32
+	maximize bool
+	best     float32
+	seen     bool
+}
+
+func newMonitor(name string, mode Mode, minDelta float32) monitor {
+	if minDelta < 0 {
+		log.Fatalf("Monitor min delta cannot be negative, got %f", minDelta)
+	}
+	var maximize bool
+	switch mode {
+	case Auto:
+		maximize = strings.Contains(name, "accuracy")
+	case Min:
+	case Max:
+		maximize = true
+	default:
+		log.Fatalf("Unknown monitor mode %d", mode)
+	}
+	return monitor{name: name, minDelta: minDelta, maximize: maximize}
+}
+
+// improved whether the monitored value in logs beats the best by more than minDelta, keeping it if so
+func (m *monitor) improved(logs nn.Logs) bool {
+	value, ok := logs[m.name]
+	if !ok {
+		log.Fatalf("Cannot monitor %s, it isn't in the logs", m.name)
+	}
+	better := value < m.best-m.minDelta
+	if m.maximize {
+		better = value > m.best+m.minDelta
+	}
+	if !m.seen || better {
+		m.best, m.seen = value, true
+		return true
+	}
+	return false
+}
+
+// TerminateOnNaN stops training as soon as a batch loss is NaN or infinite
+type TerminateOnNaN struct {
+	nn.BaseCallback
+}
+
+func (c TerminateOnNaN) OnBatchEnd(m *nn.Model, batch int, logs nn.Logs) {
+	if loss := logs["loss"]; math.IsNaN(loss) || math.IsInf(loss, 0) {
+		log.Printf("Batch %d has loss %f, terminating training", batch, loss)
+		m.StopTraining()
+	}
+}
+
+// LambdaCallback calls whichever of its functions are set, for a quick callback without a new type
+type LambdaCallback struct {
+	TrainBegin func(m *nn.Model, logs nn.Logs)
+	TrainEnd   func(m *nn.Model, logs nn.Logs)
+	EpochBegin func(m *nn.Model, epoch int, logs nn.Logs)
+	EpochEnd   func(m *nn.Model, epoch int, logs nn.Logs)
+	BatchBegin func(m *nn.Model, batch int, logs nn.Logs)
+	BatchEnd   func(m *nn.Model, batch int, logs nn.Logs)
+}
+
+func (c LambdaCallback) OnTrainBegin(m *nn.Model, logs nn.Logs) {
+	if c.TrainBegin != nil {
+		c.TrainBegin(m, logs)
+	}
+}
+
+func (c LambdaCallback) OnTrainEnd(m *nn.Model, logs nn.Logs) {
+	if c.TrainEnd != nil {
+		c.TrainEnd(m, logs)
+	}
+}
+
+func (c LambdaCallback) OnEpochBegin(m *nn.Model, epoch int, logs nn.Logs) {
+	if c.EpochBegin != nil {
+		c.EpochBegin(m, epoch, logs)
+	}
+}
+
+func (c LambdaCallback) OnEpochEnd(m *nn.Model, epoch int, logs nn.Logs) {
+	if c.EpochEnd != nil {
+		c.EpochEnd(m, epoch, logs)
+	}
+}
+
+func (c LambdaCallback) OnBatchBegin(m *nn.Model, batch int, logs nn.Logs) {
+	if c.BatchBegin != nil {
+		c.BatchBegin(m, batch, logs)
+	}
+}
+
+func (c LambdaCallback) OnBatchEnd(m *nn.Model, batch int, logs nn.Logs) {
+	if c.BatchEnd != nil {
+		c.BatchEnd(m, batch, logs)
+	}
+}
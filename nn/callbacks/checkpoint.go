@@ -0,0 +1,48 @@
+package callbacks
+
+import (
+	"log"
+	"nn-go/nn"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ModelCheckpoint saves the weights of the model to path at the end of each epoch, see
+// nn.Model.SaveWeights. Any %d in the path, e.g. "weights-%d.gob", is replaced by the epoch number
+// from 1, any other % is kept as it is. With bestOnly it only saves when the monitored log improves,
+// mode saying whether it improves by going down or up
+type ModelCheckpoint struct {
+	nn.BaseCallback
+	path     string
+	monitor  monitor
+	bestOnly bool
+}
+
+func NewModelCheckpoint(path string, monitor string, mode Mode, bestOnly bool) *ModelCheckpoint {
+	return &ModelCheckpoint{path: path, monitor: newMonitor(monitor, mode, 0), bestOnly: bestOnly}
+}
+
+func (c *ModelCheckpoint) OnTrainBegin(*nn.Model, nn.Logs) {
+	c.monitor.seen = false
+}
+
+func (c *ModelCheckpoint) OnEpochEnd(m *nn.Model, epoch int, logs nn.Logs) {
+	if !c.monitor.improved(logs) && c.bestOnly {
+		return
+	}
+	path := strings.ReplaceAll(c.path, "%d", strconv.Itoa(epoch+1))
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func(f *os.File) {
+		err := f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}(f)
+	if err := m.SaveWeights(f); err != nil {
+		log.Fatal(err)
+	}
+}
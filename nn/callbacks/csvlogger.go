@@ -0,0 +1,47 @@
+package callbacks
+
+import (
+	"encoding/csv"
+	"io"
+	"log"
+	"nn-go/nn"
+	"sort"
+	"strconv"
+)
+
+// CSVLogger writes the logs of each epoch to a CSV, with a header of the epoch and the log names
+// in alphabetical order
+type CSVLogger struct {
+	nn.BaseCallback
+	writer *csv.Writer
+	keys   []string
+}
+
+func NewCSVLogger(w io.Writer) *CSVLogger {
+	return &CSVLogger{writer: csv.NewWriter(w)}
+}
+
+func (c *CSVLogger) OnEpochEnd(_ *nn.Model, epoch int, logs nn.Logs) {
+	if c.keys == nil {
+		for k := range logs {
+			c.keys = append(c.keys, k)
+		}
+		sort.Strings(c.keys)
+		c.write(append([]string{"epoch"}, c.keys...))
+	}
+	row := []string{strconv.Itoa(epoch)}
+	for _, k := range c.keys {
+		row = append(row, strconv.FormatFloat(float64(logs[k]), 'g', -1, 32))
+	}
+	c.write(row)
+}
+
+func (c *CSVLogger) write(record []string) {
+	if err := c.writer.Write(record); err != nil {
+		log.Fatal(err)
+	}
+	c.writer.Flush()
+	if err := c.writer.Error(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,63 @@
+package callbacks
+
+import (
+	"log"
+	"nn-go/nn"
+)
+
+// EarlyStopping stops training once the monitored log, usually val_loss, hasn't improved by more than
+// minDelta for patience epochs. Mode says whether the log improves by going down or up. With
+// restoreBest the model ends with the weights of its best epoch, which replace the averaged weights
+// of nn.SWA, so don't use both
+type EarlyStopping struct {
+	nn.BaseCallback
+	monitor     monitor
+	patience    int
+	restoreBest bool
+	wait        int
+	bestEpoch   int
+	bestWeights []*nn.Matrix
+	// StoppedEpoch the epoch training stopped after, or -1 if it wasn't stopped
+	StoppedEpoch int
+}
+
+func NewEarlyStopping(monitor string, mode Mode, minDelta float32, patience int, restoreBest bool) *EarlyStopping {
+	if patience < 0 {
+		log.Fatalf("Early stopping patience cannot be negative, got %d", patience)
+	}
+	return &EarlyStopping{monitor: newMonitor(monitor, mode, minDelta), patience: patience, restoreBest: restoreBest}
+}
+
+func (e *EarlyStopping) OnTrainBegin(*nn.Model, nn.Logs) {
+	e.monitor.seen = false
+	e.wait = 0
+	e.bestWeights = nil
+	e.StoppedEpoch = -1
+}
+
+func (e *EarlyStopping) OnEpochEnd(m *nn.Model, epoch int, logs nn.Logs) {
+	if e.monitor.improved(logs) {
+		e.wait = 0
+		e.bestEpoch = epoch
+		if e.restoreBest {
+			e.bestWeights = m.Weights()
+		}
+		return
+	}
+	e.wait++
+	if e.wait >= e.patience {
+		e.StoppedEpoch = epoch
+		m.StopTraining()
+	}
+}
+
+func (e *EarlyStopping) OnTrainEnd(m *nn.Model, _ nn.Logs) {
+	if e.restoreBest && e.bestWeights != nil {
+		m.SetWeights(e.bestWeights)
+	}
+}
+
+// BestEpoch the epoch with the best value of the monitored log
+func (e *EarlyStopping) BestEpoch() int {
+	return e.bestEpoch
+}
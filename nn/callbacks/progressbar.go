@@ -0,0 +1,56 @@
+package callbacks
+
+import (
+	"fmt"
+	"io"
+	"nn-go/nn"
+	"sort"
+	"strings"
+)
+
+// progressWidth the number of characters in the bar
+const progressWidth = 30
+
+// ProgressBar writes a bar of each epoch's progress with the running loss, then the epoch's logs
+type ProgressBar struct {
+	nn.BaseCallback
+	w       io.Writer
+	epochs  int
+	batches int
+	loss    float32
+}
+
+func NewProgressBar(w io.Writer) *ProgressBar {
+	return &ProgressBar{w: w}
+}
+
+func (p *ProgressBar) OnTrainBegin(_ *nn.Model, logs nn.Logs) {
+	p.epochs, p.batches = int(logs["epochs"]), int(logs["batches"])
+}
+
+func (p *ProgressBar) OnEpochBegin(_ *nn.Model, epoch int, _ nn.Logs) {
+	p.loss = 0
+	fmt.Fprintf(p.w, "Epoch %d/%d\n", epoch+1, p.epochs)
+}
+
+func (p *ProgressBar) OnBatchEnd(_ *nn.Model, batch int, logs nn.Logs) {
+	p.loss += logs["loss"]
+	done := (batch + 1) * progressWidth / p.batches
+	bar := strings.Repeat("=", done) + strings.Repeat(" ", progressWidth-done)
+	fmt.Fprintf(p.w, "\r%d/%d [%s] loss: %.4f", batch+1, p.batches, bar, p.loss/float32(batch+1))
+}
+
+func (p *ProgressBar) OnEpochEnd(_ *nn.Model, _ int, logs nn.Logs) {
+	var keys []string
+	for k := range logs {
+		if k != "duration" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(p.w, " - %.0fms", logs["duration"]*1000)
+	for _, k := range keys {
+		fmt.Fprintf(p.w, " - %s: %.4g", k, logs[k])
+	}
+	fmt.Fprintln(p.w)
+}
@@ -0,0 +1,73 @@
+package nn
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"log"
+)
+
+// allParams the parameters of every layer, including frozen ones
+func (m *Model) allParams() []*Param {
+	var params []*Param
+	for _, l := range m.layers {
+		if p, ok := l.(ParamLayer); ok {
+			params = append(params, p.Params()...)
+		}
+	}
+	return params
+}
+
+// Weights a copy of the value of every parameter of every layer, including frozen ones
+func (m *Model) Weights() []*Matrix {
+	var weights []*Matrix
+	for _, p := range m.allParams() {
+		weights = append(weights, p.Value.Copy())
+	}
+	return weights
+}
+
+// SetWeights copy weights from Weights, of a model with the same layers, into the parameters
+func (m *Model) SetWeights(weights []*Matrix) {
+	params := m.allParams()
+	if len(weights) != len(params) {
+		log.Fatalf("Model has %d parameters, got %d weights", len(params), len(weights))
+	}
+	for i, p := range params {
+		p.Value.check(weights[i])
+		for r, row := range weights[i].v {
+			copy(p.Value.v[r], row)
+		}
+	}
+}
+
+// SaveWeights write the weights of the model to w
+func (m *Model) SaveWeights(w io.Writer) error {
+	var values [][][]float32
+	for _, weights := range m.Weights() {
+		values = append(values, weights.v)
+	}
+	return gob.NewEncoder(w).Encode(values)
+}
+
+// LoadWeights read weights written by SaveWeights, of a model with the same layers, into the parameters
+func (m *Model) LoadWeights(r io.Reader) error {
+	var values [][][]float32
+	if err := gob.NewDecoder(r).Decode(&values); err != nil {
+		return err
+	}
+	params := m.allParams()
+	if len(values) != len(params) {
+		return fmt.Errorf("model has %d parameters, got %d weights", len(params), len(values))
+	}
+	weights := make([]*Matrix, len(values))
+	for i, v := range values {
+		if len(v) != params[i].Value.rows || (len(v) > 0 && len(v[0]) != params[i].Value.cols) {
+			return fmt.Errorf("parameter %s has shape (%d, %d), got %d rows", params[i].Name,
+				params[i].Value.rows, params[i].Value.cols, len(v))
+		}
+		weights[i] = NewMatrixFromArray(v)
+	}
+	m.SetWeights(weights)
+	return nil
+}
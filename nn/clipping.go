@@ -0,0 +1,90 @@
+package nn
+
+import (
+	math "github.com/chewxy/math32"
+	"log"
+)
+
+// GradientClipping limits the gradients before an optimizer uses them, which keeps exploding
+// gradients, e.g. in recurrent layers, from wrecking the weights. A zero field is disabled.
+// They are applied in order: Value, then Norm, then GlobalNorm
+type GradientClipping struct {
+	// Value clips every gradient value to [-Value, Value]
+	Value float32
+	// Norm rescales the gradient of each parameter whose L2 norm is above Norm down to Norm
+	Norm float32
+	// GlobalNorm rescales all the gradients together when the L2 norm of all of them is above GlobalNorm,
+	// keeping their direction
+	GlobalNorm float32
+}
+
+func (c GradientClipping) check() {
+	if c.Value < 0 || c.Norm < 0 || c.GlobalNorm < 0 {
+		log.Fatalf("Gradient clipping thresholds cannot be negative, got %+v", c)
+	}
+}
+
+// norm the L2 norm of a matrix
+func norm(m *Matrix) float32 {
+	var sum float32
+	for _, row := range m.v {
+		for _, x := range row {
+			sum += x * x
+		}
+	}
+	return math.Sqrt(sum)
+}
+
+// GlobalNorm the L2 norm of the gradients of all the params together
+func GlobalNorm(params []*Param) float32 {
+	var sum float32
+	for _, p := range params {
+		n := norm(p.Grad)
+		sum += n * n
+	}
+	return math.Sqrt(sum)
+}
+
+// Clip the gradients of params in place
+func (c GradientClipping) Clip(params []*Param) {
+	if c.Value > 0 {
+		for _, p := range params {
+			for _, row := range p.Grad.v {
+				for j, x := range row {
+					row[j] = math.Max(-c.Value, math.Min(c.Value, x))
+				}
+			}
+		}
+	}
+	if c.Norm > 0 {
+		for _, p := range params {
+			if n := norm(p.Grad); n > c.Norm {
+				p.Grad.Multn(c.Norm / n)
+			}
+		}
+	}
+	if c.GlobalNorm > 0 {
+		if n := GlobalNorm(params); n > c.GlobalNorm {
+			for _, p := range params {
+				p.Grad.Multn(c.GlobalNorm / n)
+			}
+		}
+	}
+}
+
+// clippedOptimizer clips the gradients before every step of the optimizer it wraps
+type clippedOptimizer struct {
+	Optimizer
+	clipping GradientClipping
+}
+
+// ClipGradients wrap optimizer so that it clips the gradients before every step
+func ClipGradients(optimizer Optimizer, clipping GradientClipping) Optimizer {
+	clipping.check()
+	return &clippedOptimizer{optimizer, clipping}
+}
+
+func (c *clippedOptimizer) Step(params []*Param) {
+	c.clipping.Clip(params)
+	c.Optimizer.Step(params)
+}
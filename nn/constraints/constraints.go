@@ -0,0 +1,96 @@
+package constraints
+
+import (
+	math "github.com/chewxy/math32"
+	"log"
+	"nn-go/nn"
+)
+
+// epsilon keeps the rescaling of all-zero columns finite
+const epsilon = 1e-7
+
+// columnNorms the L2 norm of each column of m, e.g. of the weights into each unit of a Dense kernel
+func columnNorms(m *nn.Matrix) []float32 {
+	norms := make([]float32, m.Cols())
+	for j := range norms {
+		var sum float32
+		for i := 0; i < m.Rows(); i++ {
+			sum += m.Get(i, j) * m.Get(i, j)
+		}
+		norms[j] = math.Sqrt(sum)
+	}
+	return norms
+}
+
+// rescale each column of m from its norm to the norm returned by fn
+func rescale(m *nn.Matrix, fn func(norm float32) float32) {
+	for j, norm := range columnNorms(m) {
+		scale := fn(norm) / (norm + epsilon)
+		for i := 0; i < m.Rows(); i++ {
+			m.Set(i, j, m.Get(i, j)*scale)
+		}
+	}
+}
+
+// MaxNorm rescales each column whose L2 norm is above max down to max
+type MaxNorm struct {
+	max float32
+}
+
+func NewMaxNormConstraint(max float32) MaxNorm {
+	if max <= 0 {
+		log.Fatalf("Max norm must be positive, got %f", max)
+	}
+	return MaxNorm{max}
+}
+
+func (c MaxNorm) Apply(m *nn.Matrix) {
+	rescale(m, func(norm float32) float32 {
+		return math.Min(norm, c.max)
+	})
+}
+
+// UnitNorm rescales each column to an L2 norm of 1
+type UnitNorm struct{}
+
+func (c UnitNorm) Apply(m *nn.Matrix) {
+	rescale(m, func(float32) float32 {
+		return 1
+	})
+}
+
+// NonNeg sets negative values to 0
+type NonNeg struct{}
+
+func (c NonNeg) Apply(m *nn.Matrix) {
+	for i := 0; i < m.Rows(); i++ {
+		for j := 0; j < m.Cols(); j++ {
+			m.Set(i, j, math.Max(m.Get(i, j), 0))
+		}
+	}
+}
+
+// MinMaxNorm moves the L2 norm of each column rate of the way into [min, max]. A rate of 1 clips
+// the norm, a smaller rate lets it drift in gradually
+type MinMaxNorm struct {
+	min  float32
+	max  float32
+	rate float32
+}
+
+func NewMinMaxNormConstraint(min float32, max float32, rate float32) MinMaxNorm {
+	if min < 0 || max < min {
+		log.Fatalf("Norm range must be in 0 <= min <= max, got [%f, %f]", min, max)
+	}
+	if rate <= 0 || rate > 1 {
+		log.Fatalf("Norm constraint rate must be in (0, 1], got %f", rate)
+	}
+	return MinMaxNorm{min, max, rate}
+}
+
+func (c MinMaxNorm) Apply(m *nn.Matrix) {
+	rescale(m, func(norm float32) float32 {
+		clipped := math.Min(math.Max(norm, c.min), c.max)
+		return c.rate*clipped + (1-c.rate)*norm
+	})
+}
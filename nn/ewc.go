@@ -0,0 +1,102 @@
+package nn
+
+import "log"
+
+// EWC elastic weight consolidation, which stops training on a new task from forgetting the tasks
+// consolidated before it. Each task anchors the params at their values after training on it, and
+// Train adds the penalty lambda/2 * sum(F * (value - anchor)^2) to the loss, where F, the diagonal of
+// the Fisher information, measures how much each value mattered to the task
+type EWC struct {
+	lambda float32
+	tasks  []ewcTask
+}
+
+// ewcTask the anchored values and their Fisher information for one consolidated task
+type ewcTask struct {
+	anchors map[*Param]*Matrix
+	fisher  map[*Param]*Matrix
+}
+
+// NewEWC create an empty EWC, lambda trades off remembering old tasks against learning new ones
+func NewEWC(lambda float32) *EWC {
+	if lambda < 0 {
+		log.Fatalf("EWC lambda cannot be negative, got %f", lambda)
+	}
+	return &EWC{lambda: lambda}
+}
+
+// Consolidate the task of data, after m has been trained on it. The diagonal Fisher information is
+// estimated from the squared gradients of the loss of each instance, averaged over data. Regularizer
+// penalties are left out, they say nothing about what the task needs
+func (e *EWC) Consolidate(m *Model, data DataSet) {
+	m.setPenaltyGradients(false)
+	defer m.setPenaltyGradients(true)
+	params := m.Params()
+	task := ewcTask{anchors: map[*Param]*Matrix{}, fisher: map[*Param]*Matrix{}}
+	for _, p := range params {
+		task.anchors[p] = p.Value.Copy()
+		task.fisher[p] = NewMatrixLike(p.Value)
+		p.ZeroGrad()
+	}
+	n := data.Instances.rows
+	for i := 0; i < n; i++ {
+		x, y, weights := data.getBatch(1, i)
+		activations := m.Forward(x)
+		_, grads, layers := m.trainingLoss(activations, y, weights)
+		m.backward(activations, grads, layers)
+		for _, p := range params {
+			fisher := task.fisher[p]
+			for r, row := range p.Grad.v {
+				for c, g := range row {
+					fisher.v[r][c] += g * g / float32(n)
+				}
+			}
+			p.ZeroGrad()
+		}
+	}
+	e.tasks = append(e.tasks, task)
+}
+
+// Tasks how many tasks have been consolidated
+func (e *EWC) Tasks() int {
+	return len(e.tasks)
+}
+
+// Penalty the anchoring penalty of params over every consolidated task
+func (e *EWC) Penalty(params []*Param) float32 {
+	var penalty float32
+	for _, task := range e.tasks {
+		for _, p := range params {
+			fisher, ok := task.fisher[p]
+			if !ok {
+				continue
+			}
+			anchor := task.anchors[p]
+			for r, row := range p.Value.v {
+				for c, x := range row {
+					d := x - anchor.v[r][c]
+					penalty += fisher.v[r][c] * d * d
+				}
+			}
+		}
+	}
+	return e.lambda / 2 * penalty
+}
+
+// AddGradients add the gradient of the penalty, lambda * F * (value - anchor), to the Grad of params
+func (e *EWC) AddGradients(params []*Param) {
+	for _, task := range e.tasks {
+		for _, p := range params {
+			fisher, ok := task.fisher[p]
+			if !ok {
+				continue
+			}
+			anchor := task.anchors[p]
+			for r, row := range p.Grad.v {
+				for c := range row {
+					row[c] += e.lambda * fisher.v[r][c] * (p.Value.v[r][c] - anchor.v[r][c])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package nn
+
+import "time"
+
+// validationPrefix the prefix of the names of metrics on the test data in History.Metrics
+const validationPrefix = "val_"
+
+// History what happened while training, with one value per epoch unless noted
+type History struct {
+	// TrainLoss the mean batch loss, including any penalties
+	TrainLoss []float32
+	// ValidationLoss the loss on the test data at the end of the epoch
+	ValidationLoss []float32
+	// Metrics each metric by name, averaged over the batches, and on the test data with the name prefixed by val_
+	Metrics map[string][]float32
+	// EpochDuration how long the epoch took, including evaluation
+	EpochDuration []time.Duration
+	// Lr the learning rate of the optimizer at the end of the epoch, before any per-epoch schedule
+	Lr []float32
+	// BatchLoss the loss of every batch, including any penalties
+	BatchLoss []float32
+	// GradientNorms the global L2 norm of the gradients of every weight update, before any clipping
+	GradientNorms []float32
+}
+
+func newHistory(metrics []Metric) History {
+	history := History{Metrics: map[string][]float32{}}
+	for _, metric := range metrics {
+		history.Metrics[metric.Name()] = nil
+		history.Metrics[validationPrefix+metric.Name()] = nil
+	}
+	return history
+}
+
+// Epochs how many epochs have been recorded
+func (h History) Epochs() int {
+	return len(h.TrainLoss)
+}
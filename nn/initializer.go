@@ -3,3 +3,10 @@ package nn
 type Initializer interface {
 	Call(layer Layer) float32
 }
+
+// MatrixInitializer is an Initializer that needs to see the whole weight matrix at once,
+// e.g. to make it orthogonal. Matrix.Initialize will prefer InitMatrix when it is available
+type MatrixInitializer interface {
+	Initializer
+	InitMatrix(m *Matrix, layer Layer)
+}
@@ -45,3 +45,67 @@ func (c Const) Call(nn.Layer) float32 {
 func NewConstInitializer(val float32) Const {
 	return Const{val}
 }
+
+// Orthogonal fills a weight matrix with a (semi-)orthogonal matrix scaled by gain.
+// Typically used for the recurrent kernel of recurrent layers
+type Orthogonal struct {
+	gain float32
+}
+
+func NewOrthogonalInitializer(gain float32) Orthogonal {
+	return Orthogonal{gain}
+}
+
+// Call is only used when a single value is needed, it draws from N(0, gain^2/fanIn)
+func (o Orthogonal) Call(layer nn.Layer) float32 {
+	return o.gain * float32(rand.NormFloat64()) / math.Sqrt(float32(layer.Inputs()))
+}
+
+// InitMatrix orthonormalises a random gaussian matrix with Gram-Schmidt.
+// When the matrix is wide the rows are orthonormal, otherwise the columns are
+func (o Orthogonal) InitMatrix(m *nn.Matrix, _ nn.Layer) {
+	rows, cols := m.Shape()
+	n, length := cols, rows
+	if rows < cols {
+		n, length = rows, cols
+	}
+	vecs := make([][]float32, n)
+	for i := range vecs {
+		for {
+			vecs[i] = make([]float32, length)
+			for k := range vecs[i] {
+				vecs[i][k] = float32(rand.NormFloat64())
+			}
+			for j := 0; j < i; j++ {
+				var dot float32
+				for k := range vecs[i] {
+					dot += vecs[i][k] * vecs[j][k]
+				}
+				for k := range vecs[i] {
+					vecs[i][k] -= dot * vecs[j][k]
+				}
+			}
+			var norm float32
+			for k := range vecs[i] {
+				norm += vecs[i][k] * vecs[i][k]
+			}
+			norm = math.Sqrt(norm)
+			// a degenerate draw is astronomically unlikely, but draw again rather than divide by 0
+			if norm > 1e-6 {
+				for k := range vecs[i] {
+					vecs[i][k] /= norm
+				}
+				break
+			}
+		}
+	}
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			if rows < cols {
+				m.Set(i, j, o.gain*vecs[i][j])
+			} else {
+				m.Set(i, j, o.gain*vecs[j][i])
+			}
+		}
+	}
+}
@@ -1,9 +1,40 @@
 package nn
 
+// Layer a single step of a Model. Init receives the shape of a sample coming into the layer,
+// checks that the layer can handle it, creates its weights and returns the shape it outputs.
+// Backward adds the gradients of the layer's parameters, if any, to their Grad and returns
+// the gradient of its input
 type Layer interface {
-	Init(inputs int) int
+	Init(input Shape) Shape
 	Forward(input *Matrix) *Matrix
-	Backward(input *Matrix, grads *Matrix, optimizer Optimizer) *Matrix
+	Backward(input *Matrix, grads *Matrix) *Matrix
 	Inputs() int
 	Outputs() int
 }
+
+// MergeLayer combines the outputs of several layers into one, e.g. for residual connections.
+// Backward returns the gradient of each input, in the same order as the inputs. To use one in a
+// Model, wrap it and the layers whose outputs it combines in a layers.Branches
+type MergeLayer interface {
+	Init(inputs []Shape) Shape
+	Forward(inputs []*Matrix) *Matrix
+	Backward(inputs []*Matrix, grads *Matrix) []*Matrix
+	Inputs() int
+	Outputs() int
+}
+
+// SoftmaxLayer is implemented by layers that only apply a softmax over their input.
+// When one ends a Model whose loss is a FusedSoftmaxLoss, Model skips it in training
+// and uses the fused loss on its input instead
+type SoftmaxLayer interface {
+	Layer
+	IsSoftmax() bool
+}
+
+// TrainableLayer is implemented by layers whose parameters can be frozen. Backward of a frozen layer
+// still returns the gradient of its input, but leaves the Grad of its params alone
+type TrainableLayer interface {
+	Layer
+	SetTrainable(trainable bool)
+	Trainable() bool
+}
@@ -0,0 +1,43 @@
+package layers
+
+import (
+	"nn-go/nn"
+	"nn-go/nn/activations"
+)
+
+// Activation applies an activation function to every value of the input
+type Activation struct {
+	units     int
+	activator nn.Activator
+}
+
+func (l *Activation) Init(input nn.Shape) nn.Shape {
+	l.units = input.Size()
+	return input
+}
+
+func (l *Activation) Forward(input *nn.Matrix) *nn.Matrix {
+	return input.Activate(l.activator)
+}
+
+// Backward pass through the network, the activation has no weights to update
+func (l *Activation) Backward(input *nn.Matrix, grads *nn.Matrix) *nn.Matrix {
+	return input.Derivative(l.activator).Mult(grads)
+}
+
+func (l *Activation) Inputs() int {
+	return l.units
+}
+
+func (l *Activation) Outputs() int {
+	return l.units
+}
+
+func NewActivationLayer(activator nn.Activator) *Activation {
+	return &Activation{0, activator}
+}
+
+// NewReluLayer an Activation layer applying ReLU
+func NewReluLayer() *Activation {
+	return NewActivationLayer(activations.ReLU)
+}
@@ -0,0 +1,228 @@
+package layers
+
+import (
+	math "github.com/chewxy/math32"
+	"log"
+	"nn-go/nn"
+)
+
+// maskedScore stands in for -inf so that fully masked rows don't produce NaN
+const maskedScore = -1e9
+
+// MultiHeadAttention scaled dot-product self-attention over input of shape
+// (timesteps, features). Each of the heads projects the input to queries,
+// keys and values of keyDim, and the concatenated heads are projected back to features
+type MultiHeadAttention struct {
+	features        int
+	timesteps       int
+	heads           int
+	keyDim          int
+	causal          bool
+	paddingMask     *nn.Matrix
+	queryWeights    *nn.Param
+	keyWeights      *nn.Param
+	valueWeights    *nn.Param
+	outputWeights   *nn.Param
+	queryBiases     *nn.Param
+	keyBiases       *nn.Param
+	valueBiases     *nn.Param
+	outputBiases    *nn.Param
+	initializer     nn.Initializer
+	biasInitializer nn.Initializer
+	attention       [][]*nn.Matrix
+	learning        bool
+}
+
+func (l *MultiHeadAttention) Init(input nn.Shape) nn.Shape {
+	if len(input) != 2 {
+		log.Fatalf("Attention expects input of shape (timesteps, features), got %v", input)
+	}
+	l.timesteps, l.features = input[0], input[1]
+	projected := l.heads * l.keyDim
+	l.queryWeights = nn.NewParam("query_kernel", nn.NewMatrix(l.features, projected).Initialize(l.initializer, l))
+	l.keyWeights = nn.NewParam("key_kernel", nn.NewMatrix(l.features, projected).Initialize(l.initializer, l))
+	l.valueWeights = nn.NewParam("value_kernel", nn.NewMatrix(l.features, projected).Initialize(l.initializer, l))
+	l.outputWeights = nn.NewParam("output_kernel", nn.NewMatrix(projected, l.features).Initialize(l.initializer, l))
+	l.queryBiases = nn.NewParam("query_bias", nn.NewMatrix(1, projected).Initialize(l.biasInitializer, l))
+	l.keyBiases = nn.NewParam("key_bias", nn.NewMatrix(1, projected).Initialize(l.biasInitializer, l))
+	l.valueBiases = nn.NewParam("value_bias", nn.NewMatrix(1, projected).Initialize(l.biasInitializer, l))
+	l.outputBiases = nn.NewParam("output_bias", nn.NewMatrix(1, l.features).Initialize(l.biasInitializer, l))
+	return input
+}
+
+// SetPaddingMask mask out padded timesteps so no query attends to them.
+// mask is a (batch, timesteps) matrix with 1 for real timesteps and 0 for padding,
+// nil removes the mask
+func (l *MultiHeadAttention) SetPaddingMask(mask *nn.Matrix) {
+	l.paddingMask = mask
+}
+
+// AttentionWeights the attention weights of the most recent forward pass, indexed by
+// sample then head. Each is a (timesteps, timesteps) matrix where row i holds how much
+// timestep i attends to every timestep
+func (l *MultiHeadAttention) AttentionWeights() [][]*nn.Matrix {
+	return l.attention
+}
+
+// attentionStep the values for a single sample needed for backpropagation
+type attentionStep struct {
+	q, k, v *nn.Matrix
+	weights []*nn.Matrix
+	context *nn.Matrix
+}
+
+// project a (batch*timesteps, features) matrix through weights and biases
+func project(x *nn.Matrix, weights *nn.Matrix, biases *nn.Matrix) *nn.Matrix {
+	return x.Product(weights).Add(biases)
+}
+
+// scores the masked, scaled attention scores of query and key for sample b
+func (l *MultiHeadAttention) scores(q *nn.Matrix, k *nn.Matrix, b int) *nn.Matrix {
+	s := q.Product(k.T()).Multn(1 / math.Sqrt(float32(l.keyDim)))
+	for i := 0; i < l.timesteps; i++ {
+		for j := 0; j < l.timesteps; j++ {
+			if (l.causal && j > i) || (l.paddingMask != nil && l.paddingMask.Get(b, j) == 0) {
+				s.Set(i, j, maskedScore)
+			}
+		}
+	}
+	return s
+}
+
+func (l *MultiHeadAttention) forward(input *nn.Matrix) (*nn.Matrix, []*attentionStep) {
+	batch := input.Rows()
+	if l.paddingMask != nil && (l.paddingMask.Rows() != batch || l.paddingMask.Cols() != l.timesteps) {
+		log.Fatalf("Padding mask must be (%d, %d), got (%d, %d)",
+			batch, l.timesteps, l.paddingMask.Rows(), l.paddingMask.Cols())
+	}
+	x := input.Reshape(batch*l.timesteps, l.features)
+	q := project(x, l.queryWeights.Value, l.queryBiases.Value)
+	k := project(x, l.keyWeights.Value, l.keyBiases.Value)
+	v := project(x, l.valueWeights.Value, l.valueBiases.Value)
+	steps := make([]*attentionStep, batch)
+	l.attention = make([][]*nn.Matrix, batch)
+	var contexts []*nn.Matrix
+	for b := range steps {
+		start, end := b*l.timesteps, (b+1)*l.timesteps
+		s := &attentionStep{q: q.SliceRows(start, end), k: k.SliceRows(start, end), v: v.SliceRows(start, end)}
+		var heads []*nn.Matrix
+		for h := 0; h < l.heads; h++ {
+			from, to := h*l.keyDim, (h+1)*l.keyDim
+			weights := l.scores(s.q.SliceCols(from, to), s.k.SliceCols(from, to), b).Softmax()
+			s.weights = append(s.weights, weights)
+			heads = append(heads, weights.Product(s.v.SliceCols(from, to)))
+		}
+		s.context = nn.ConcatCols(heads...)
+		contexts = append(contexts, s.context)
+		l.attention[b] = s.weights
+		steps[b] = s
+	}
+	output := project(nn.ConcatRows(contexts...), l.outputWeights.Value, l.outputBiases.Value)
+	return output.Reshape(batch, l.timesteps*l.features), steps
+}
+
+func (l *MultiHeadAttention) Forward(input *nn.Matrix) *nn.Matrix {
+	output, _ := l.forward(input)
+	return output
+}
+
+// Backward pass through the network, accumulating the weight gradients if learning enabled
+func (l *MultiHeadAttention) Backward(input *nn.Matrix, grads *nn.Matrix) *nn.Matrix {
+	_, steps := l.forward(input)
+	batch := input.Rows()
+	x := input.Reshape(batch*l.timesteps, l.features)
+	dy := grads.Reshape(batch*l.timesteps, l.features)
+	var contexts, dqs, dks, dvs []*nn.Matrix
+	scale := 1 / math.Sqrt(float32(l.keyDim))
+	for b, s := range steps {
+		dContext := dy.SliceRows(b*l.timesteps, (b+1)*l.timesteps).Product(l.outputWeights.Value.T())
+		dq, dk, dv := nn.NewMatrixLike(s.q), nn.NewMatrixLike(s.k), nn.NewMatrixLike(s.v)
+		for h, weights := range s.weights {
+			from, to := h*l.keyDim, (h+1)*l.keyDim
+			dHead := dContext.SliceCols(from, to)
+			dv.SetCols(from, weights.T().Product(dHead))
+			dWeights := dHead.Product(s.v.SliceCols(from, to).T())
+			// Backpropagate each row through the softmax, dS_ij = A_ij (dA_ij - sum_k dA_ik A_ik)
+			dScores := nn.NewMatrixLike(weights)
+			for i := 0; i < l.timesteps; i++ {
+				var dot float32
+				for j := 0; j < l.timesteps; j++ {
+					dot += dWeights.Get(i, j) * weights.Get(i, j)
+				}
+				for j := 0; j < l.timesteps; j++ {
+					dScores.Set(i, j, weights.Get(i, j)*(dWeights.Get(i, j)-dot)*scale)
+				}
+			}
+			dq.SetCols(from, dScores.Product(s.k.SliceCols(from, to)))
+			dk.SetCols(from, dScores.T().Product(s.q.SliceCols(from, to)))
+		}
+		contexts = append(contexts, s.context)
+		dqs, dks, dvs = append(dqs, dq), append(dks, dk), append(dvs, dv)
+	}
+	dq, dk, dv := nn.ConcatRows(dqs...), nn.ConcatRows(dks...), nn.ConcatRows(dvs...)
+	gradInput := dq.Product(l.queryWeights.Value.T()).
+		Add(dk.Product(l.keyWeights.Value.T())).
+		Add(dv.Product(l.valueWeights.Value.T()))
+	if l.learning {
+		l.outputWeights.Grad.Add(nn.ConcatRows(contexts...).T().Product(dy))
+		l.outputBiases.Grad.Add(dy.SumCols())
+		l.queryWeights.Grad.Add(x.T().Product(dq))
+		l.queryBiases.Grad.Add(dq.SumCols())
+		l.keyWeights.Grad.Add(x.T().Product(dk))
+		l.keyBiases.Grad.Add(dk.SumCols())
+		l.valueWeights.Grad.Add(x.T().Product(dv))
+		l.valueBiases.Grad.Add(dv.SumCols())
+	}
+	return gradInput.Reshape(batch, l.timesteps*l.features)
+}
+
+// Params the query, key, value and output projections
+func (l *MultiHeadAttention) Params() []*nn.Param {
+	return []*nn.Param{
+		l.queryWeights, l.queryBiases,
+		l.keyWeights, l.keyBiases,
+		l.valueWeights, l.valueBiases,
+		l.outputWeights, l.outputBiases,
+	}
+}
+
+// SetTrainable freeze the layer's weights when false, Backward still returns the input gradient
+func (l *MultiHeadAttention) SetTrainable(trainable bool) {
+	l.learning = trainable
+}
+
+// Trainable whether Backward accumulates the gradients of the layer's weights
+func (l *MultiHeadAttention) Trainable() bool {
+	return l.learning
+}
+
+// Inputs the number of features in each timestep
+func (l *MultiHeadAttention) Inputs() int {
+	return l.features
+}
+
+// Outputs the number of features in each timestep
+func (l *MultiHeadAttention) Outputs() int {
+	return l.features
+}
+
+// NewMultiHeadAttentionLayer create a self-attention layer with heads heads of keyDim.
+// With causal each timestep can only attend to itself and earlier timesteps
+func NewMultiHeadAttentionLayer(
+	heads int,
+	keyDim int,
+	causal bool,
+	initializer nn.Initializer,
+	biasInitializer nn.Initializer) *MultiHeadAttention {
+	if heads < 1 || keyDim < 1 {
+		log.Fatalf("Attention needs at least 1 head of at least 1 dimension, got %d heads of %d\n", heads, keyDim)
+	}
+	return &MultiHeadAttention{
+		heads:           heads,
+		keyDim:          keyDim,
+		causal:          causal,
+		initializer:     initializer,
+		biasInitializer: biasInitializer,
+		learning:        true,
+	}
+}
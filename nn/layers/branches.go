@@ -0,0 +1,165 @@
+package layers
+
+import (
+	"fmt"
+	"log"
+	"nn-go/nn"
+)
+
+// Branches passes its input through several branches of layers and combines their outputs with
+// a merge layer, so multi-branch blocks can be added to a Model like any other layer. A branch
+// without layers passes the input through unchanged, e.g. for the skip of a residual connection
+type Branches struct {
+	merge     nn.MergeLayer
+	branches  [][]nn.Layer
+	inputs    int
+	params    []*nn.Param
+	trainable bool
+}
+
+func (l *Branches) Init(input nn.Shape) nn.Shape {
+	l.inputs = input.Size()
+	outputs := make([]nn.Shape, len(l.branches))
+	l.params = nil
+	for b, branch := range l.branches {
+		shape := input.Copy()
+		for i, layer := range branch {
+			shape = layer.Init(shape.Copy())
+			if !shape.Valid() {
+				log.Fatalf("Layer %d (%T) of branch %d has invalid output shape %v", i, layer, b, shape)
+			}
+			if p, ok := layer.(nn.ParamLayer); ok {
+				for _, param := range p.Params() {
+					param.Name = fmt.Sprintf("branch%d/%d/%s", b, i, param.Name)
+					l.params = append(l.params, param)
+				}
+			}
+		}
+		outputs[b] = shape
+	}
+	l.SetTrainable(l.trainable)
+	return l.merge.Init(outputs)
+}
+
+// forward the activations of every branch, activations[b][i] is the input of layer i of branch b
+// and the last is the branch's output
+func (l *Branches) forward(input *nn.Matrix) [][]*nn.Matrix {
+	activations := make([][]*nn.Matrix, len(l.branches))
+	for b, branch := range l.branches {
+		activations[b] = []*nn.Matrix{input}
+		for _, layer := range branch {
+			activations[b] = append(activations[b], layer.Forward(activations[b][len(activations[b])-1]))
+		}
+	}
+	return activations
+}
+
+// branchOutputs the output of each branch
+func branchOutputs(activations [][]*nn.Matrix) []*nn.Matrix {
+	out := make([]*nn.Matrix, len(activations))
+	for b, a := range activations {
+		out[b] = a[len(a)-1]
+	}
+	return out
+}
+
+func (l *Branches) Forward(input *nn.Matrix) *nn.Matrix {
+	return l.merge.Forward(branchOutputs(l.forward(input)))
+}
+
+// Backward through the merge and then each branch, the input gradient is the sum over the branches
+func (l *Branches) Backward(input *nn.Matrix, grads *nn.Matrix) *nn.Matrix {
+	activations := l.forward(input)
+	branchGrads := l.merge.Backward(branchOutputs(activations), grads)
+	gradInput := nn.NewMatrixLike(input)
+	for b, branch := range l.branches {
+		g := branchGrads[b]
+		for i := len(branch) - 1; i >= 0; i-- {
+			g = branch[i].Backward(activations[b][i], g)
+		}
+		gradInput.Add(g)
+	}
+	return gradInput
+}
+
+// Params the parameters of every layer in the branches, with their names prefixed by the branch
+// and the layer's index in it
+func (l *Branches) Params() []*nn.Param {
+	return l.params
+}
+
+// Penalty the total penalty of the regularized layers in the branches
+func (l *Branches) Penalty(input *nn.Matrix, _ *nn.Matrix) float32 {
+	var penalty float32
+	for b, a := range l.forward(input) {
+		for i, layer := range l.branches[b] {
+			if r, ok := layer.(nn.RegularizedLayer); ok {
+				penalty += r.Penalty(a[i], a[i+1])
+			}
+		}
+	}
+	return penalty
+}
+
+// SetPenaltyGradients whether the Backward of the regularized layers in the branches includes the
+// gradients of their penalties
+func (l *Branches) SetPenaltyGradients(enabled bool) {
+	for _, branch := range l.branches {
+		for _, layer := range branch {
+			if r, ok := layer.(nn.RegularizedLayer); ok {
+				r.SetPenaltyGradients(enabled)
+			}
+		}
+	}
+}
+
+// Constrain apply the constraints of the layers in the branches
+func (l *Branches) Constrain() {
+	for _, branch := range l.branches {
+		for _, layer := range branch {
+			if c, ok := layer.(nn.ConstrainedLayer); ok {
+				c.Constrain()
+			}
+		}
+	}
+}
+
+// SetTrainable freeze the weights of every layer in the branches when false, Backward still
+// returns the input gradient
+func (l *Branches) SetTrainable(trainable bool) {
+	l.trainable = trainable
+	for _, branch := range l.branches {
+		for _, layer := range branch {
+			if t, ok := layer.(nn.TrainableLayer); ok {
+				t.SetTrainable(trainable)
+			}
+		}
+	}
+}
+
+// Trainable whether Backward accumulates the gradients of the branches' weights
+func (l *Branches) Trainable() bool {
+	return l.trainable
+}
+
+func (l *Branches) Inputs() int {
+	return l.inputs
+}
+
+func (l *Branches) Outputs() int {
+	return l.merge.Outputs()
+}
+
+// NewBranchesLayer create a layer combining the outputs of branches with merge, each branch is a
+// sequence of layers applied to the input in order
+func NewBranchesLayer(merge nn.MergeLayer, branches ...[]nn.Layer) *Branches {
+	if len(branches) < 1 {
+		log.Fatal("Branches needs at least 1 branch")
+	}
+	return &Branches{merge: merge, branches: branches, trainable: true}
+}
+
+// NewResidualLayer create a residual block adding the input to the output of layers
+func NewResidualLayer(layers ...nn.Layer) *Branches {
+	return NewBranchesLayer(NewAddLayer(), nil, layers)
+}
@@ -20,9 +20,19 @@ type Conv1d struct {
 	learning        bool
 }
 
-func (l *Conv1d) Init(inputs int) int {
-	l.filters = inputs
-	return inputs
+// Init takes input of shape (steps, channels) and outputs (steps, filters)
+func (l *Conv1d) Init(input nn.Shape) nn.Shape {
+	if len(input) != 2 {
+		log.Fatalf("Conv1d expects input of shape (steps, channels), got %v", input)
+	}
+	l.inputs = input[1]
+	steps := input[0]
+	if l.padding == Padding(VALID) {
+		steps = (steps-l.kernelSize)/l.strides + 1
+	} else {
+		steps = (steps + l.strides - 1) / l.strides
+	}
+	return nn.Shape{steps, l.filters}
 	//l.weights = nn.NewMatrix(inputs, l.units)
 	//l.weights.Initialize(l.initializer, l)
 	//if l.useBias {
@@ -42,10 +52,20 @@ func (l *Conv1d) Forward(input *nn.Matrix) *nn.Matrix {
 }
 
 // Backward pass through the network, updating weights if learning enabled
-func (l *Conv1d) Backward(input *nn.Matrix, grads *nn.Matrix, optimizer *nn.Optimizer) *nn.Matrix {
+func (l *Conv1d) Backward(input *nn.Matrix, grads *nn.Matrix) *nn.Matrix {
 	return grads
 }
 
+// SetTrainable freeze the layer's weights when false, Backward still returns the input gradient
+func (l *Conv1d) SetTrainable(trainable bool) {
+	l.learning = trainable
+}
+
+// Trainable whether Backward accumulates the gradients of the layer's weights
+func (l *Conv1d) Trainable() bool {
+	return l.learning
+}
+
 func (l *Conv1d) Inputs() int {
 	return l.inputs
 }
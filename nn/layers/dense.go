@@ -5,49 +5,174 @@ import (
 	"nn-go/nn"
 )
 
+// Dense a fully connected layer. When the input has more than one dimension the layer
+// is applied to the innermost dimension at every position, e.g. to each timestep of a sequence
 type Dense struct {
 	inputs          int
+	positions       int
 	units           int
-	weights         *nn.Matrix
+	kernel          *nn.Param
 	activator       nn.Activator
 	initializer     nn.Initializer
 	biasInitializer nn.Initializer
 	useBias         bool
-	biases          *nn.Matrix
+	bias            *nn.Param
 	learning        bool
+	// regularizers and constraints, nil when unused
+	kernelRegularizer   nn.Regularizer
+	biasRegularizer     nn.Regularizer
+	activityRegularizer nn.Regularizer
+	kernelConstraint    nn.Constraint
+	biasConstraint      nn.Constraint
+	// noPenaltyGradients leaves the gradients of the regularizers out of Backward
+	noPenaltyGradients bool
 }
 
-func (l *Dense) Init(inputs int) int {
-	l.inputs = inputs
-	l.weights = nn.NewMatrix(inputs, l.units)
-	l.weights.Initialize(l.initializer, l)
+func (l *Dense) Init(input nn.Shape) nn.Shape {
+	l.inputs = input.Last()
+	l.positions = input.Size() / l.inputs
+	l.kernel = nn.NewParam("kernel", nn.NewMatrix(l.inputs, l.units).Initialize(l.initializer, l))
 	if l.useBias {
-		l.biases = nn.NewMatrix(1, l.units)
-		l.biases.Initialize(l.biasInitializer, l)
+		l.bias = nn.NewParam("bias", nn.NewMatrix(1, l.units).Initialize(l.biasInitializer, l))
 	}
-	return l.units
+	output := input.Copy()
+	output[len(output)-1] = l.units
+	return output
 }
 
-func (l *Dense) Forward(input *nn.Matrix) *nn.Matrix {
-	result := input.Product(l.weights)
+// positionRows split each sample into one row per position
+func (l *Dense) positionRows(m *nn.Matrix) *nn.Matrix {
+	if l.positions == 1 {
+		return m
+	}
+	return m.Reshape(m.Rows()*l.positions, m.Cols()/l.positions)
+}
+
+// sampleRows join the rows of each position back into one row per sample
+func (l *Dense) sampleRows(m *nn.Matrix) *nn.Matrix {
+	if l.positions == 1 {
+		return m
+	}
+	return m.Reshape(m.Rows()/l.positions, m.Cols()*l.positions)
+}
+
+// preActivation xW + b, with one row per position
+func (l *Dense) preActivation(input *nn.Matrix) *nn.Matrix {
+	result := l.positionRows(input).Product(l.kernel.Value)
 	if l.useBias {
-		result.Add(l.biases)
+		result.Add(l.bias.Value)
 	}
-	result.ActivateInPlace(l.activator)
 	return result
 }
 
-// Backward pass through the network, updating weights if learning enabled
-func (l *Dense) Backward(input *nn.Matrix, gradOutput *nn.Matrix, optimizer nn.Optimizer) *nn.Matrix {
-	gradInput := gradOutput.Product(l.weights.T())
-	gradWeights := input.T().Product(gradInput)
-	l.weights.Sub(gradWeights.Multn(optimizer.Lr()))
+func (l *Dense) Forward(input *nn.Matrix) *nn.Matrix {
+	return l.sampleRows(l.preActivation(input).ActivateInPlace(l.activator))
+}
+
+// Backward pass through the network, accumulating the weight gradients if learning enabled.
+// The gradients of any penalties are included
+func (l *Dense) Backward(input *nn.Matrix, gradOutput *nn.Matrix) *nn.Matrix {
+	preActivation := l.preActivation(input)
+	if l.activityRegularizer != nil && !l.noPenaltyGradients {
+		output := l.sampleRows(preActivation.Activate(l.activator))
+		penaltyGrads := l.activityRegularizer.Gradient(output).Divn(float32(output.Rows()))
+		gradOutput = gradOutput.Copy().Add(penaltyGrads)
+	}
+	// Backpropagate through the activation first, the rest of the gradients are for xW + b
+	gradOutput = preActivation.Derivative(l.activator).Mult(l.positionRows(gradOutput))
+	input = l.positionRows(input)
+	gradInput := gradOutput.Product(l.kernel.Value.T())
+	if l.learning {
+		l.kernel.Grad.Add(input.T().Product(gradOutput))
+		if l.kernelRegularizer != nil && !l.noPenaltyGradients {
+			l.kernel.Grad.Add(l.kernelRegularizer.Gradient(l.kernel.Value))
+		}
+		if l.useBias {
+			l.bias.Grad.Add(gradOutput.SumCols())
+			if l.biasRegularizer != nil && !l.noPenaltyGradients {
+				l.bias.Grad.Add(l.biasRegularizer.Gradient(l.bias.Value))
+			}
+		}
+	}
+	return l.sampleRows(gradInput)
+}
+
+// Penalty the penalties of the kernel, bias and, averaged over the batch, the output
+func (l *Dense) Penalty(input *nn.Matrix, output *nn.Matrix) float32 {
+	var penalty float32
+	if l.kernelRegularizer != nil {
+		penalty += l.kernelRegularizer.Penalty(l.kernel.Value)
+	}
+	if l.useBias && l.biasRegularizer != nil {
+		penalty += l.biasRegularizer.Penalty(l.bias.Value)
+	}
+	if l.activityRegularizer != nil {
+		penalty += l.activityRegularizer.Penalty(output) / float32(output.Rows())
+	}
+	return penalty
+}
 
+// SetPenaltyGradients whether Backward includes the gradients of the penalties, on by default
+func (l *Dense) SetPenaltyGradients(enabled bool) {
+	l.noPenaltyGradients = !enabled
+}
+
+// Constrain apply the kernel and bias constraints
+func (l *Dense) Constrain() {
+	if l.kernelConstraint != nil {
+		l.kernelConstraint.Apply(l.kernel.Value)
+	}
+	if l.useBias && l.biasConstraint != nil {
+		l.biasConstraint.Apply(l.bias.Value)
+	}
+}
+
+// WithKernelRegularizer add regularizer's penalty on the kernel to the loss
+func (l *Dense) WithKernelRegularizer(regularizer nn.Regularizer) *Dense {
+	l.kernelRegularizer = regularizer
+	return l
+}
+
+// WithBiasRegularizer add regularizer's penalty on the bias to the loss
+func (l *Dense) WithBiasRegularizer(regularizer nn.Regularizer) *Dense {
+	l.biasRegularizer = regularizer
+	return l
+}
+
+// WithActivityRegularizer add regularizer's penalty on the output, averaged over the batch, to the loss
+func (l *Dense) WithActivityRegularizer(regularizer nn.Regularizer) *Dense {
+	l.activityRegularizer = regularizer
+	return l
+}
+
+// WithKernelConstraint apply constraint to the kernel after every update
+func (l *Dense) WithKernelConstraint(constraint nn.Constraint) *Dense {
+	l.kernelConstraint = constraint
+	return l
+}
+
+// WithBiasConstraint apply constraint to the bias after every update
+func (l *Dense) WithBiasConstraint(constraint nn.Constraint) *Dense {
+	l.biasConstraint = constraint
+	return l
+}
+
+// Params the kernel and, when used, the bias
+func (l *Dense) Params() []*nn.Param {
 	if l.useBias {
-		gradBiases := gradOutput.MeanCols().Multn(float32(l.inputs))
-		l.biases.Sub(gradBiases.Multn(optimizer.Lr()))
+		return []*nn.Param{l.kernel, l.bias}
 	}
-	return gradInput
+	return []*nn.Param{l.kernel}
+}
+
+// SetTrainable freeze the layer's weights when false, Backward still returns the input gradient
+func (l *Dense) SetTrainable(trainable bool) {
+	l.learning = trainable
+}
+
+// Trainable whether Backward accumulates the gradients of the layer's weights
+func (l *Dense) Trainable() bool {
+	return l.learning
 }
 
 func (l *Dense) Inputs() int {
@@ -66,18 +191,25 @@ func NewDenseLayer(
 	if units < 1 {
 		log.Fatalf("Layers units must be more than 1, got %d\n", units)
 	}
-	var biases *nn.Matrix
-	var weights *nn.Matrix
+	var bias *nn.Param
+	var kernel *nn.Param
 
 	return &Dense{
 		0,
+		1,
 		units,
-		weights,
+		kernel,
 		activator,
 		initializer,
 		biasInitializer,
 		useBias,
-		biases,
+		bias,
 		true,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		false,
 	}
 }
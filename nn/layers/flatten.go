@@ -0,0 +1,36 @@
+package layers
+
+import (
+	"nn-go/nn"
+)
+
+// Flatten collapses the input into a single dimension, e.g. between sequence layers and Dense.
+// Samples are already stored flattened so the values are passed through unchanged
+type Flatten struct {
+	units int
+}
+
+func (l *Flatten) Init(input nn.Shape) nn.Shape {
+	l.units = input.Size()
+	return nn.Shape{l.units}
+}
+
+func (l *Flatten) Forward(input *nn.Matrix) *nn.Matrix {
+	return input.Copy()
+}
+
+func (l *Flatten) Backward(_ *nn.Matrix, grads *nn.Matrix) *nn.Matrix {
+	return grads
+}
+
+func (l *Flatten) Inputs() int {
+	return l.units
+}
+
+func (l *Flatten) Outputs() int {
+	return l.units
+}
+
+func NewFlattenLayer() *Flatten {
+	return &Flatten{}
+}
@@ -0,0 +1,125 @@
+package layers
+
+import (
+	"nn-go/nn"
+	"nn-go/nn/activations"
+)
+
+// GRU a gated recurrent unit layer. The kernels hold the update, reset and
+// candidate weights side by side, in that order. The reset gate is applied
+// before the recurrent kernel, h_t = z*h_{t-1} + (1-z)*tanh(x W_h + (r*h_{t-1}) R_h + b_h)
+type GRU struct {
+	recurrent
+}
+
+// gruStep the values at a single timestep needed for backpropagation
+type gruStep struct {
+	x, hPrev, rh *nn.Matrix
+	z, r, hh, h  *nn.Matrix
+}
+
+func (l *GRU) forward(input *nn.Matrix) []*gruStep {
+	h := l.startStates(input.Rows())[0]
+	steps := make([]*gruStep, l.timesteps)
+	u := l.units
+	rk := l.recurrentKernel.Value
+	for t := range steps {
+		s := &gruStep{x: l.step(input, t), hPrev: h}
+		xw := s.x.Product(l.kernel.Value)
+		if l.useBias {
+			xw.Add(l.biases.Value)
+		}
+		gates := xw.SliceCols(0, 2*u).Add(h.Product(rk.SliceCols(0, 2*u)))
+		s.z = gates.SliceCols(0, u).ActivateInPlace(activations.Sigmoid)
+		s.r = gates.SliceCols(u, 2*u).ActivateInPlace(activations.Sigmoid)
+		s.rh = s.r.Copy().Mult(h)
+		s.hh = xw.SliceCols(2*u, 3*u).Add(s.rh.Product(rk.SliceCols(2*u, 3*u))).ActivateInPlace(activations.Tanh)
+		s.h = nn.NewMatrixLike(h)
+		for r := 0; r < h.Rows(); r++ {
+			for j := 0; j < u; j++ {
+				z := s.z.Get(r, j)
+				s.h.Set(r, j, z*h.Get(r, j)+(1-z)*s.hh.Get(r, j))
+			}
+		}
+		h = s.h
+		steps[t] = s
+	}
+	return steps
+}
+
+func (l *GRU) Init(input nn.Shape) nn.Shape {
+	return l.init(input, l)
+}
+
+func (l *GRU) Forward(input *nn.Matrix) *nn.Matrix {
+	steps := l.forward(input)
+	hs := make([]*nn.Matrix, len(steps))
+	for t, s := range steps {
+		hs[t] = s.h
+	}
+	return l.output(hs, hs[len(hs)-1:])
+}
+
+// Backward pass through time, accumulating the weight gradients if learning enabled
+func (l *GRU) Backward(input *nn.Matrix, grads *nn.Matrix) *nn.Matrix {
+	steps := l.forward(input)
+	dhs, dStates := l.splitGrads(grads)
+	g := l.newGrads(input.Rows())
+	dh := dStates[0]
+	u := l.units
+	rkCandidate := l.recurrentKernel.Value.SliceCols(2*u, 3*u)
+	for t := l.timesteps - 1; t >= 0; t-- {
+		s := steps[t]
+		dh.Add(dhs[t])
+		batch := dh.Rows()
+		dPrev := nn.NewMatrix(batch, u)
+		daHH := nn.NewMatrix(batch, u)
+		dz := nn.NewMatrix(batch, 3*u)
+		for r := 0; r < batch; r++ {
+			for j := 0; j < u; j++ {
+				dhv := dh.Get(r, j)
+				z := s.z.Get(r, j)
+				dz.Set(r, j, dhv*(s.hPrev.Get(r, j)-s.hh.Get(r, j))*sigmoidGrad(z))
+				daHH.Set(r, j, dhv*(1-z)*tanhGrad(s.hh.Get(r, j)))
+				dPrev.Set(r, j, dhv*z)
+			}
+		}
+		dz.SetCols(2*u, daHH)
+		dRH := daHH.Product(rkCandidate.T())
+		for r := 0; r < batch; r++ {
+			for j := 0; j < u; j++ {
+				dz.Set(r, u+j, dRH.Get(r, j)*s.hPrev.Get(r, j)*sigmoidGrad(s.r.Get(r, j)))
+				dPrev.Set(r, j, dPrev.Get(r, j)+dRH.Get(r, j)*s.r.Get(r, j))
+			}
+		}
+		// The candidate's recurrent kernel sees r*h rather than h, so it is handled separately
+		gates := dz.SliceCols(0, 2*u)
+		g.kernel.Add(s.x.T().Product(dz))
+		g.recurrentKernel.AddCols(0, s.hPrev.T().Product(gates))
+		g.recurrentKernel.AddCols(2*u, s.rh.T().Product(daHH))
+		g.biases.Add(dz.SumCols())
+		g.input.AddCols(t*l.features, dz.Product(l.kernel.Value.T()))
+		dh = dPrev.Add(gates.Product(l.recurrentKernel.Value.SliceCols(0, 2*u).T()))
+		if l.cut(t) && t > 0 {
+			dh = nn.NewMatrixLike(dh)
+		}
+	}
+	l.initialStateGrads = []*nn.Matrix{dh}
+	l.store(g)
+	return g.input
+}
+
+// NewGRULayer create a GRU over input of shape (timesteps, features). With returnSequences
+// the output has shape (timesteps, units), otherwise it holds only the last hidden state.
+// With returnState the final hidden state is appended, flattening the output
+func NewGRULayer(
+	units int,
+	returnSequences bool,
+	returnState bool,
+	useBias bool,
+	initializer nn.Initializer,
+	recurrentInitializer nn.Initializer,
+	biasInitializer nn.Initializer) *GRU {
+	return &GRU{newRecurrent(units, 3, 1, returnSequences, returnState, useBias,
+		initializer, recurrentInitializer, biasInitializer)}
+}
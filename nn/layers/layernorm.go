@@ -0,0 +1,108 @@
+package layers
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+)
+
+// LayerNormalization normalizes the innermost dimension of the input to zero mean and unit variance,
+// then scales by gamma and shifts by beta. For sequences each timestep is normalized separately
+type LayerNormalization struct {
+	inputs   int
+	size     int
+	epsilon  float32
+	gamma    *nn.Param
+	beta     *nn.Param
+	learning bool
+}
+
+func (l *LayerNormalization) Init(input nn.Shape) nn.Shape {
+	l.inputs = input.Size()
+	l.size = input.Last()
+	l.gamma = nn.NewParam("gamma", nn.NewMatrix(1, l.size).Fill(1))
+	l.beta = nn.NewParam("beta", nn.NewMatrix(1, l.size))
+	return input
+}
+
+// normalize each group of the input, returning the normalized values and the inverse standard deviations
+func (l *LayerNormalization) normalize(input *nn.Matrix) (*nn.Matrix, *nn.Matrix) {
+	groups := input.Reshape(input.Rows()*input.Cols()/l.size, l.size)
+	invStd := nn.NewMatrix(groups.Rows(), 1)
+	for i := 0; i < groups.Rows(); i++ {
+		var mean, variance float32
+		for j := 0; j < l.size; j++ {
+			mean += groups.Get(i, j)
+		}
+		mean /= float32(l.size)
+		for j := 0; j < l.size; j++ {
+			d := groups.Get(i, j) - mean
+			variance += d * d
+		}
+		inv := 1 / math.Sqrt(variance/float32(l.size)+l.epsilon)
+		invStd.Set(i, 0, inv)
+		for j := 0; j < l.size; j++ {
+			groups.Set(i, j, (groups.Get(i, j)-mean)*inv)
+		}
+	}
+	return groups, invStd
+}
+
+func (l *LayerNormalization) Forward(input *nn.Matrix) *nn.Matrix {
+	normalized, _ := l.normalize(input)
+	return normalized.Mult(l.gamma.Value).Add(l.beta.Value).Reshape(input.Rows(), input.Cols())
+}
+
+// Backward pass through the network, accumulating the gamma and beta gradients if learning enabled
+func (l *LayerNormalization) Backward(input *nn.Matrix, grads *nn.Matrix) *nn.Matrix {
+	normalized, invStd := l.normalize(input)
+	dy := grads.Reshape(normalized.Rows(), l.size)
+	gradInput := nn.NewMatrixLike(normalized)
+	n := float32(l.size)
+	for i := 0; i < normalized.Rows(); i++ {
+		var sum, dot float32
+		for j := 0; j < l.size; j++ {
+			dxhat := dy.Get(i, j) * l.gamma.Value.Get(0, j)
+			sum += dxhat
+			dot += dxhat * normalized.Get(i, j)
+		}
+		for j := 0; j < l.size; j++ {
+			dxhat := dy.Get(i, j) * l.gamma.Value.Get(0, j)
+			gradInput.Set(i, j, invStd.Get(i, 0)*(dxhat-sum/n-normalized.Get(i, j)*dot/n))
+		}
+	}
+	if l.learning {
+		l.gamma.Grad.Add(dy.Copy().Mult(normalized).SumCols())
+		l.beta.Grad.Add(dy.SumCols())
+	}
+	return gradInput.Reshape(input.Rows(), input.Cols())
+}
+
+// Params the scale gamma and the shift beta
+func (l *LayerNormalization) Params() []*nn.Param {
+	return []*nn.Param{l.gamma, l.beta}
+}
+
+// SetTrainable freeze the layer's weights when false, Backward still returns the input gradient
+func (l *LayerNormalization) SetTrainable(trainable bool) {
+	l.learning = trainable
+}
+
+// Trainable whether Backward accumulates the gradients of the layer's weights
+func (l *LayerNormalization) Trainable() bool {
+	return l.learning
+}
+
+func (l *LayerNormalization) Inputs() int {
+	return l.inputs
+}
+
+func (l *LayerNormalization) Outputs() int {
+	return l.inputs
+}
+
+func NewLayerNormalizationLayer(epsilon float32) *LayerNormalization {
+	return &LayerNormalization{
+		epsilon:  epsilon,
+		learning: true,
+	}
+}
@@ -0,0 +1,104 @@
+package layers
+
+import (
+	"nn-go/nn"
+	"nn-go/nn/activations"
+)
+
+// LSTM a long short-term memory layer. The kernels hold the input, forget, cell and
+// output gates side by side, in that order
+type LSTM struct {
+	recurrent
+}
+
+// lstmStep the values at a single timestep needed for backpropagation
+type lstmStep struct {
+	x, hPrev, cPrev *nn.Matrix
+	i, f, g, o      *nn.Matrix
+	c, tanhC, h     *nn.Matrix
+}
+
+func (l *LSTM) forward(input *nn.Matrix) []*lstmStep {
+	start := l.startStates(input.Rows())
+	h, c := start[0], start[1]
+	steps := make([]*lstmStep, l.timesteps)
+	u := l.units
+	for t := range steps {
+		s := &lstmStep{x: l.step(input, t), hPrev: h, cPrev: c}
+		z := l.preActivation(s.x, h)
+		s.i = z.SliceCols(0, u).ActivateInPlace(activations.Sigmoid)
+		s.f = z.SliceCols(u, 2*u).ActivateInPlace(activations.Sigmoid)
+		s.g = z.SliceCols(2*u, 3*u).ActivateInPlace(activations.Tanh)
+		s.o = z.SliceCols(3*u, 4*u).ActivateInPlace(activations.Sigmoid)
+		s.c = s.f.Copy().Mult(c).Add(s.i.Copy().Mult(s.g))
+		s.tanhC = s.c.Activate(activations.Tanh)
+		s.h = s.o.Copy().Mult(s.tanhC)
+		h, c = s.h, s.c
+		steps[t] = s
+	}
+	return steps
+}
+
+func (l *LSTM) Init(input nn.Shape) nn.Shape {
+	return l.init(input, l)
+}
+
+func (l *LSTM) Forward(input *nn.Matrix) *nn.Matrix {
+	steps := l.forward(input)
+	hs := make([]*nn.Matrix, len(steps))
+	for t, s := range steps {
+		hs[t] = s.h
+	}
+	last := steps[len(steps)-1]
+	return l.output(hs, []*nn.Matrix{last.h, last.c})
+}
+
+// Backward pass through time, accumulating the weight gradients if learning enabled
+func (l *LSTM) Backward(input *nn.Matrix, grads *nn.Matrix) *nn.Matrix {
+	steps := l.forward(input)
+	dhs, dStates := l.splitGrads(grads)
+	g := l.newGrads(input.Rows())
+	dh, dc := dStates[0], dStates[1]
+	u := l.units
+	for t := l.timesteps - 1; t >= 0; t-- {
+		s := steps[t]
+		dh.Add(dhs[t])
+		dz := nn.NewMatrix(input.Rows(), 4*u)
+		for r := 0; r < dz.Rows(); r++ {
+			for j := 0; j < u; j++ {
+				dhv := dh.Get(r, j)
+				tc := s.tanhC.Get(r, j)
+				o := s.o.Get(r, j)
+				dcv := dc.Get(r, j) + dhv*o*tanhGrad(tc)
+				dz.Set(r, j, dcv*s.g.Get(r, j)*sigmoidGrad(s.i.Get(r, j)))
+				dz.Set(r, u+j, dcv*s.cPrev.Get(r, j)*sigmoidGrad(s.f.Get(r, j)))
+				dz.Set(r, 2*u+j, dcv*s.i.Get(r, j)*tanhGrad(s.g.Get(r, j)))
+				dz.Set(r, 3*u+j, dhv*tc*sigmoidGrad(o))
+				dc.Set(r, j, dcv*s.f.Get(r, j))
+			}
+		}
+		dh = l.accumulate(g, t, s.x, s.hPrev, dz)
+		if l.cut(t) && t > 0 {
+			dh = nn.NewMatrixLike(dh)
+			dc = nn.NewMatrixLike(dc)
+		}
+	}
+	l.initialStateGrads = []*nn.Matrix{dh, dc}
+	l.store(g)
+	return g.input
+}
+
+// NewLSTMLayer create an LSTM over input of shape (timesteps, features). With returnSequences
+// the output has shape (timesteps, units), otherwise it holds only the last hidden state.
+// With returnState the final hidden and cell states are appended, flattening the output
+func NewLSTMLayer(
+	units int,
+	returnSequences bool,
+	returnState bool,
+	useBias bool,
+	initializer nn.Initializer,
+	recurrentInitializer nn.Initializer,
+	biasInitializer nn.Initializer) *LSTM {
+	return &LSTM{newRecurrent(units, 4, 2, returnSequences, returnState, useBias,
+		initializer, recurrentInitializer, biasInitializer)}
+}
@@ -0,0 +1,275 @@
+package layers
+
+import (
+	"log"
+	"nn-go/nn"
+)
+
+// merge holds what the merge layers have in common
+type merge struct {
+	inputs  []nn.Shape
+	outputs int
+}
+
+// initSame check that there are at least min inputs all of the same shape
+func (l *merge) initSame(inputs []nn.Shape, min int) nn.Shape {
+	if len(inputs) < min {
+		log.Fatalf("Merge layer needs at least %d inputs, got %d", min, len(inputs))
+	}
+	for _, shape := range inputs {
+		if !shape.Equal(inputs[0]) {
+			log.Fatalf("Merge layer inputs must all be the same shape, got %v", inputs)
+		}
+	}
+	l.inputs = inputs
+	l.outputs = inputs[0].Size()
+	return inputs[0].Copy()
+}
+
+// check the number of inputs passed to Forward or Backward
+func (l *merge) check(inputs []*nn.Matrix) {
+	if len(inputs) != len(l.inputs) {
+		log.Fatalf("Merge layer was initialised with %d inputs, got %d", len(l.inputs), len(inputs))
+	}
+}
+
+// Inputs the number of values in the first input
+func (l *merge) Inputs() int {
+	return l.inputs[0].Size()
+}
+
+func (l *merge) Outputs() int {
+	return l.outputs
+}
+
+// Add the element-wise sum of the inputs
+type Add struct {
+	merge
+}
+
+func (l *Add) Init(inputs []nn.Shape) nn.Shape {
+	return l.initSame(inputs, 2)
+}
+
+func (l *Add) Forward(inputs []*nn.Matrix) *nn.Matrix {
+	l.check(inputs)
+	out := inputs[0].Copy()
+	for _, input := range inputs[1:] {
+		out.Add(input)
+	}
+	return out
+}
+
+// Backward every input receives the whole gradient
+func (l *Add) Backward(inputs []*nn.Matrix, grads *nn.Matrix) []*nn.Matrix {
+	l.check(inputs)
+	out := make([]*nn.Matrix, len(inputs))
+	for i := range out {
+		out[i] = grads.Copy()
+	}
+	return out
+}
+
+func NewAddLayer() *Add {
+	return &Add{}
+}
+
+// Subtract the element-wise difference of exactly 2 inputs, first minus second
+type Subtract struct {
+	merge
+}
+
+func (l *Subtract) Init(inputs []nn.Shape) nn.Shape {
+	if len(inputs) != 2 {
+		log.Fatalf("Subtract needs exactly 2 inputs, got %d", len(inputs))
+	}
+	return l.initSame(inputs, 2)
+}
+
+func (l *Subtract) Forward(inputs []*nn.Matrix) *nn.Matrix {
+	l.check(inputs)
+	return inputs[0].Copy().Sub(inputs[1])
+}
+
+func (l *Subtract) Backward(inputs []*nn.Matrix, grads *nn.Matrix) []*nn.Matrix {
+	l.check(inputs)
+	return []*nn.Matrix{grads.Copy(), grads.Copy().Multn(-1)}
+}
+
+func NewSubtractLayer() *Subtract {
+	return &Subtract{}
+}
+
+// Multiply the element-wise product of the inputs
+type Multiply struct {
+	merge
+}
+
+func (l *Multiply) Init(inputs []nn.Shape) nn.Shape {
+	return l.initSame(inputs, 2)
+}
+
+func (l *Multiply) Forward(inputs []*nn.Matrix) *nn.Matrix {
+	l.check(inputs)
+	out := inputs[0].Copy()
+	for _, input := range inputs[1:] {
+		out.Mult(input)
+	}
+	return out
+}
+
+// Backward each input receives the gradient times the product of the other inputs
+func (l *Multiply) Backward(inputs []*nn.Matrix, grads *nn.Matrix) []*nn.Matrix {
+	l.check(inputs)
+	out := make([]*nn.Matrix, len(inputs))
+	for i := range out {
+		out[i] = grads.Copy()
+		for j, input := range inputs {
+			if i != j {
+				out[i].Mult(input)
+			}
+		}
+	}
+	return out
+}
+
+func NewMultiplyLayer() *Multiply {
+	return &Multiply{}
+}
+
+// Average the element-wise mean of the inputs
+type Average struct {
+	merge
+}
+
+func (l *Average) Init(inputs []nn.Shape) nn.Shape {
+	return l.initSame(inputs, 2)
+}
+
+func (l *Average) Forward(inputs []*nn.Matrix) *nn.Matrix {
+	l.check(inputs)
+	out := inputs[0].Copy()
+	for _, input := range inputs[1:] {
+		out.Add(input)
+	}
+	return out.Divn(float32(len(inputs)))
+}
+
+// Backward every input receives an equal share of the gradient
+func (l *Average) Backward(inputs []*nn.Matrix, grads *nn.Matrix) []*nn.Matrix {
+	l.check(inputs)
+	out := make([]*nn.Matrix, len(inputs))
+	for i := range out {
+		out[i] = grads.Copy().Divn(float32(len(inputs)))
+	}
+	return out
+}
+
+func NewAverageLayer() *Average {
+	return &Average{}
+}
+
+// Maximum the element-wise maximum of the inputs
+type Maximum struct {
+	merge
+}
+
+func (l *Maximum) Init(inputs []nn.Shape) nn.Shape {
+	return l.initSame(inputs, 2)
+}
+
+// argMax the index of the input with the largest value at i,j. Ties go to the first input
+func argMax(inputs []*nn.Matrix, i int, j int) int {
+	best := 0
+	for k, input := range inputs {
+		if input.Get(i, j) > inputs[best].Get(i, j) {
+			best = k
+		}
+	}
+	return best
+}
+
+func (l *Maximum) Forward(inputs []*nn.Matrix) *nn.Matrix {
+	l.check(inputs)
+	out := nn.NewMatrixLike(inputs[0])
+	for i := 0; i < out.Rows(); i++ {
+		for j := 0; j < out.Cols(); j++ {
+			out.Set(i, j, inputs[argMax(inputs, i, j)].Get(i, j))
+		}
+	}
+	return out
+}
+
+// Backward the gradient of each value goes only to the input that held the maximum
+func (l *Maximum) Backward(inputs []*nn.Matrix, grads *nn.Matrix) []*nn.Matrix {
+	l.check(inputs)
+	out := make([]*nn.Matrix, len(inputs))
+	for k := range out {
+		out[k] = nn.NewMatrixLike(grads)
+	}
+	for i := 0; i < grads.Rows(); i++ {
+		for j := 0; j < grads.Cols(); j++ {
+			out[argMax(inputs, i, j)].Set(i, j, grads.Get(i, j))
+		}
+	}
+	return out
+}
+
+func NewMaximumLayer() *Maximum {
+	return &Maximum{}
+}
+
+// Concatenate joins the inputs along their innermost dimension, in order.
+// All the other dimensions must match
+type Concatenate struct {
+	merge
+	positions int
+}
+
+func (l *Concatenate) Init(inputs []nn.Shape) nn.Shape {
+	if len(inputs) < 1 {
+		log.Fatal("Concatenate needs at least 1 input")
+	}
+	output := inputs[0].Copy()
+	output[len(output)-1] = 0
+	for _, shape := range inputs {
+		if len(shape) != len(output) || !shape[:len(shape)-1].Equal(output[:len(output)-1]) {
+			log.Fatalf("Concatenate inputs must only differ in their last dimension, got %v", inputs)
+		}
+		output[len(output)-1] += shape.Last()
+	}
+	l.inputs = inputs
+	l.outputs = output.Size()
+	l.positions = output.Size() / output.Last()
+	return output
+}
+
+func (l *Concatenate) Forward(inputs []*nn.Matrix) *nn.Matrix {
+	l.check(inputs)
+	if l.positions == 1 {
+		return nn.ConcatCols(inputs...)
+	}
+	rows := make([]*nn.Matrix, len(inputs))
+	for i, input := range inputs {
+		rows[i] = input.Reshape(input.Rows()*l.positions, l.inputs[i].Last())
+	}
+	return nn.ConcatCols(rows...).Reshape(inputs[0].Rows(), l.outputs)
+}
+
+// Backward each input receives the part of the gradient it contributed
+func (l *Concatenate) Backward(inputs []*nn.Matrix, grads *nn.Matrix) []*nn.Matrix {
+	l.check(inputs)
+	batch := grads.Rows()
+	positionGrads := grads.Reshape(batch*l.positions, l.outputs/l.positions)
+	out := make([]*nn.Matrix, len(inputs))
+	start := 0
+	for i, shape := range l.inputs {
+		out[i] = positionGrads.SliceCols(start, start+shape.Last()).Reshape(batch, shape.Size())
+		start += shape.Last()
+	}
+	return out
+}
+
+func NewConcatenateLayer() *Concatenate {
+	return &Concatenate{}
+}
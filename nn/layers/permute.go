@@ -0,0 +1,97 @@
+package layers
+
+import (
+	"log"
+	"nn-go/nn"
+)
+
+// Permute reorders the dimensions of the input. Dimension i of the output is dimension
+// dims[i] of the input, counting from 0 and not including the batch, so
+// NewPermuteLayer(1, 0) swaps the timesteps and features of a sequence
+type Permute struct {
+	dims    []int
+	input   nn.Shape
+	output  nn.Shape
+	forward []int
+}
+
+func (l *Permute) Init(input nn.Shape) nn.Shape {
+	if len(input) != len(l.dims) {
+		log.Fatalf("Permute %v needs input with %d dimensions, got %v", l.dims, len(l.dims), input)
+	}
+	seen := make([]bool, len(l.dims))
+	l.output = make(nn.Shape, len(l.dims))
+	for i, d := range l.dims {
+		if d < 0 || d >= len(l.dims) || seen[d] {
+			log.Fatalf("Permute dims %v are not a permutation of 0..%d", l.dims, len(l.dims)-1)
+		}
+		seen[d] = true
+		l.output[i] = input[d]
+	}
+	l.input = input.Copy()
+	l.forward = permutation(l.input, l.dims)
+	return l.output.Copy()
+}
+
+// permutation the index in the output of each value of the input
+func permutation(input nn.Shape, dims []int) []int {
+	inStrides := strides(input)
+	output := make(nn.Shape, len(dims))
+	for i, d := range dims {
+		output[i] = input[d]
+	}
+	outStrides := strides(output)
+	index := make([]int, input.Size())
+	for k := range index {
+		to := 0
+		for i, d := range dims {
+			to += (k / inStrides[d] % input[d]) * outStrides[i]
+		}
+		index[k] = to
+	}
+	return index
+}
+
+// strides how far apart consecutive values of each dimension are in a flattened sample
+func strides(shape nn.Shape) []int {
+	out := make([]int, len(shape))
+	stride := 1
+	for i := len(shape) - 1; i >= 0; i-- {
+		out[i] = stride
+		stride *= shape[i]
+	}
+	return out
+}
+
+func (l *Permute) Forward(input *nn.Matrix) *nn.Matrix {
+	out := nn.NewMatrixLike(input)
+	for i := 0; i < input.Rows(); i++ {
+		for k, to := range l.forward {
+			out.Set(i, to, input.Get(i, k))
+		}
+	}
+	return out
+}
+
+// Backward moves each gradient back to where its value came from
+func (l *Permute) Backward(_ *nn.Matrix, grads *nn.Matrix) *nn.Matrix {
+	out := nn.NewMatrixLike(grads)
+	for i := 0; i < grads.Rows(); i++ {
+		for k, to := range l.forward {
+			out.Set(i, k, grads.Get(i, to))
+		}
+	}
+	return out
+}
+
+func (l *Permute) Inputs() int {
+	return l.input.Size()
+}
+
+func (l *Permute) Outputs() int {
+	return l.output.Size()
+}
+
+func NewPermuteLayer(dims ...int) *Permute {
+	return &Permute{dims: dims}
+}
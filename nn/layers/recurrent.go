@@ -0,0 +1,271 @@
+package layers
+
+import (
+	"log"
+	"nn-go/nn"
+)
+
+// recurrent holds what SimpleRNN, LSTM and GRU have in common.
+// They take input of shape (timesteps, features)
+type recurrent struct {
+	features             int
+	timesteps            int
+	units                int
+	gates                int
+	states               int
+	returnSequences      bool
+	returnState          bool
+	truncate             int
+	kernel               *nn.Param
+	recurrentKernel      *nn.Param
+	biases               *nn.Param
+	initializer          nn.Initializer
+	recurrentInitializer nn.Initializer
+	biasInitializer      nn.Initializer
+	useBias              bool
+	initialState         []*nn.Matrix
+	initialStateGrads    []*nn.Matrix
+	learning             bool
+}
+
+func newRecurrent(
+	units int,
+	gates int,
+	states int,
+	returnSequences bool,
+	returnState bool,
+	useBias bool,
+	initializer nn.Initializer,
+	recurrentInitializer nn.Initializer,
+	biasInitializer nn.Initializer) recurrent {
+	if units < 1 {
+		log.Fatalf("Layers units must be more than 1, got %d\n", units)
+	}
+	return recurrent{
+		units:                units,
+		gates:                gates,
+		states:               states,
+		returnSequences:      returnSequences,
+		returnState:          returnState,
+		initializer:          initializer,
+		recurrentInitializer: recurrentInitializer,
+		biasInitializer:      biasInitializer,
+		useBias:              useBias,
+		learning:             true,
+	}
+}
+
+// init create the weights for a layer with input of shape (timesteps, features)
+func (l *recurrent) init(input nn.Shape, layer nn.Layer) nn.Shape {
+	if len(input) != 2 {
+		log.Fatalf("Recurrent layers expect input of shape (timesteps, features), got %v", input)
+	}
+	l.timesteps, l.features = input[0], input[1]
+	l.kernel = nn.NewParam("kernel", nn.NewMatrix(l.features, l.gates*l.units).Initialize(l.initializer, layer))
+	l.recurrentKernel = nn.NewParam("recurrent_kernel",
+		nn.NewMatrix(l.units, l.gates*l.units).Initialize(l.recurrentInitializer, layer))
+	if l.useBias {
+		l.biases = nn.NewParam("bias", nn.NewMatrix(1, l.gates*l.units).Initialize(l.biasInitializer, layer))
+	}
+	if l.returnSequences && !l.returnState {
+		return nn.Shape{l.timesteps, l.units}
+	}
+	return nn.Shape{l.outputWidth()}
+}
+
+func (l *recurrent) outputWidth() int {
+	width := l.units
+	if l.returnSequences {
+		width *= l.timesteps
+	}
+	if l.returnState {
+		width += l.states * l.units
+	}
+	return width
+}
+
+// Inputs the number of features in each timestep
+func (l *recurrent) Inputs() int {
+	return l.features
+}
+
+// Outputs the number of units in each timestep
+func (l *recurrent) Outputs() int {
+	return l.units
+}
+
+// SetInitialState use states instead of zeros as the state before the first timestep.
+// LSTM takes the hidden and cell states, SimpleRNN and GRU only the hidden state.
+// Each state must be a (batch, units) matrix. Call with no states to go back to zeros
+func (l *recurrent) SetInitialState(states ...*nn.Matrix) {
+	if len(states) != 0 && len(states) != l.states {
+		log.Fatalf("Expected %d initial states, got %d", l.states, len(states))
+	}
+	for _, s := range states {
+		if s.Cols() != l.units {
+			log.Fatalf("Initial state must have %d cols, got %d", l.units, s.Cols())
+		}
+	}
+	l.initialState = states
+}
+
+// InitialStateGradients the gradients of the loss with respect to the initial state,
+// as calculated by the most recent call to Backward
+func (l *recurrent) InitialStateGradients() []*nn.Matrix {
+	return l.initialStateGrads
+}
+
+// SetTruncation limit backpropagation through time to chunks of steps timesteps,
+// counted back from the last timestep. The gradient carried through the recurrent
+// state is cut at every chunk boundary. 0 disables truncation
+func (l *recurrent) SetTruncation(steps int) {
+	if steps < 0 {
+		log.Fatalf("Truncation length cannot be negative, got %d", steps)
+	}
+	l.truncate = steps
+}
+
+// startStates the states before the first timestep
+func (l *recurrent) startStates(batch int) []*nn.Matrix {
+	out := make([]*nn.Matrix, l.states)
+	for i := range out {
+		if len(l.initialState) != 0 {
+			if l.initialState[i].Rows() != batch {
+				log.Fatalf("Initial state has %d rows but the batch has %d", l.initialState[i].Rows(), batch)
+			}
+			out[i] = l.initialState[i]
+		} else {
+			out[i] = nn.NewMatrix(batch, l.units)
+		}
+	}
+	return out
+}
+
+// step the input at timestep t
+func (l *recurrent) step(input *nn.Matrix, t int) *nn.Matrix {
+	return input.SliceCols(t*l.features, (t+1)*l.features)
+}
+
+// preActivation xW + hR + b for all the gates
+func (l *recurrent) preActivation(x *nn.Matrix, h *nn.Matrix) *nn.Matrix {
+	z := x.Product(l.kernel.Value).Add(h.Product(l.recurrentKernel.Value))
+	if l.useBias {
+		z.Add(l.biases.Value)
+	}
+	return z
+}
+
+// output assemble the layer output from the hidden state at each timestep and the final states
+func (l *recurrent) output(hs []*nn.Matrix, final []*nn.Matrix) *nn.Matrix {
+	var parts []*nn.Matrix
+	if l.returnSequences {
+		parts = append(parts, hs...)
+	} else {
+		parts = append(parts, hs[len(hs)-1])
+	}
+	if l.returnState {
+		parts = append(parts, final...)
+	}
+	return nn.ConcatCols(parts...)
+}
+
+// splitGrads split the output gradient into the gradient of the hidden state at each
+// timestep and the gradient of each of the final states
+func (l *recurrent) splitGrads(grads *nn.Matrix) ([]*nn.Matrix, []*nn.Matrix) {
+	if grads.Cols() != l.outputWidth() {
+		log.Fatalf("Expected gradients with %d cols, got %d", l.outputWidth(), grads.Cols())
+	}
+	batch := grads.Rows()
+	dhs := make([]*nn.Matrix, l.timesteps)
+	offset := 0
+	for t := range dhs {
+		if l.returnSequences || t == l.timesteps-1 {
+			dhs[t] = grads.SliceCols(offset, offset+l.units)
+			offset += l.units
+		} else {
+			dhs[t] = nn.NewMatrix(batch, l.units)
+		}
+	}
+	dStates := make([]*nn.Matrix, l.states)
+	for i := range dStates {
+		if l.returnState {
+			dStates[i] = grads.SliceCols(offset, offset+l.units)
+			offset += l.units
+		} else {
+			dStates[i] = nn.NewMatrix(batch, l.units)
+		}
+	}
+	return dhs, dStates
+}
+
+// cut whether the gradient carried from timestep t back to t-1 is truncated
+func (l *recurrent) cut(t int) bool {
+	return l.truncate > 0 && (l.timesteps-t)%l.truncate == 0
+}
+
+// recurrentGrads holds the gradients accumulated over every timestep
+type recurrentGrads struct {
+	kernel          *nn.Matrix
+	recurrentKernel *nn.Matrix
+	biases          *nn.Matrix
+	input           *nn.Matrix
+}
+
+func (l *recurrent) newGrads(batch int) *recurrentGrads {
+	return &recurrentGrads{
+		kernel:          nn.NewMatrixLike(l.kernel.Value),
+		recurrentKernel: nn.NewMatrixLike(l.recurrentKernel.Value),
+		biases:          nn.NewMatrix(1, l.gates*l.units),
+		input:           nn.NewMatrix(batch, l.timesteps*l.features),
+	}
+}
+
+// accumulate the gradients of the gate pre-activations dz at timestep t,
+// returning the gradient flowing into the previous hidden state through the recurrent kernel
+func (l *recurrent) accumulate(g *recurrentGrads, t int, x *nn.Matrix, hPrev *nn.Matrix, dz *nn.Matrix) *nn.Matrix {
+	g.kernel.Add(x.T().Product(dz))
+	g.recurrentKernel.Add(hPrev.T().Product(dz))
+	g.biases.Add(dz.SumCols())
+	g.input.AddCols(t*l.features, dz.Product(l.kernel.Value.T()))
+	return dz.Product(l.recurrentKernel.Value.T())
+}
+
+// store add the gradients accumulated over every timestep to the parameters
+func (l *recurrent) store(g *recurrentGrads) {
+	if !l.learning {
+		return
+	}
+	l.kernel.Grad.Add(g.kernel)
+	l.recurrentKernel.Grad.Add(g.recurrentKernel)
+	if l.useBias {
+		l.biases.Grad.Add(g.biases)
+	}
+}
+
+// Params the kernel, the recurrent kernel and, when used, the biases
+func (l *recurrent) Params() []*nn.Param {
+	if l.useBias {
+		return []*nn.Param{l.kernel, l.recurrentKernel, l.biases}
+	}
+	return []*nn.Param{l.kernel, l.recurrentKernel}
+}
+
+// SetTrainable freeze the layer's weights when false, Backward still returns the input gradient
+func (l *recurrent) SetTrainable(trainable bool) {
+	l.learning = trainable
+}
+
+// Trainable whether Backward accumulates the gradients of the layer's weights
+func (l *recurrent) Trainable() bool {
+	return l.learning
+}
+
+// sigmoidGrad the derivative of the sigmoid given its output y
+func sigmoidGrad(y float32) float32 {
+	return y * (1 - y)
+}
+
+// tanhGrad the derivative of tanh given its output y
+func tanhGrad(y float32) float32 {
+	return 1 - y*y
+}
@@ -0,0 +1,52 @@
+package layers
+
+import (
+	"log"
+	"nn-go/nn"
+)
+
+// RepeatVector repeats a vector input n times, turning shape (features) into (n, features)
+type RepeatVector struct {
+	n        int
+	features int
+}
+
+func (l *RepeatVector) Init(input nn.Shape) nn.Shape {
+	if len(input) != 1 {
+		log.Fatalf("RepeatVector expects input of shape (features), got %v", input)
+	}
+	l.features = input[0]
+	return nn.Shape{l.n, l.features}
+}
+
+func (l *RepeatVector) Forward(input *nn.Matrix) *nn.Matrix {
+	out := nn.NewMatrix(input.Rows(), l.n*l.features)
+	for t := 0; t < l.n; t++ {
+		out.SetCols(t*l.features, input)
+	}
+	return out
+}
+
+// Backward sums the gradient of every repeat
+func (l *RepeatVector) Backward(_ *nn.Matrix, grads *nn.Matrix) *nn.Matrix {
+	out := nn.NewMatrix(grads.Rows(), l.features)
+	for t := 0; t < l.n; t++ {
+		out.Add(grads.SliceCols(t*l.features, (t+1)*l.features))
+	}
+	return out
+}
+
+func (l *RepeatVector) Inputs() int {
+	return l.features
+}
+
+func (l *RepeatVector) Outputs() int {
+	return l.n * l.features
+}
+
+func NewRepeatVectorLayer(n int) *RepeatVector {
+	if n < 1 {
+		log.Fatalf("RepeatVector must repeat at least once, got %d\n", n)
+	}
+	return &RepeatVector{n: n}
+}
@@ -0,0 +1,77 @@
+package layers
+
+import (
+	"nn-go/nn"
+)
+
+// SimpleRNN a fully connected recurrent layer, h_t = activation(x_t W + h_{t-1} R + b)
+type SimpleRNN struct {
+	recurrent
+	activator nn.Activator
+}
+
+func (l *SimpleRNN) Init(input nn.Shape) nn.Shape {
+	return l.init(input, l)
+}
+
+// forward run the layer over the sequence, returning the pre-activation at each timestep
+// and the hidden state before and after each timestep. hs[0] is the initial state
+func (l *SimpleRNN) forward(input *nn.Matrix) ([]*nn.Matrix, []*nn.Matrix) {
+	hs := []*nn.Matrix{l.startStates(input.Rows())[0]}
+	var zs []*nn.Matrix
+	for t := 0; t < l.timesteps; t++ {
+		z := l.preActivation(l.step(input, t), hs[t])
+		zs = append(zs, z)
+		hs = append(hs, z.Activate(l.activator))
+	}
+	return zs, hs
+}
+
+func (l *SimpleRNN) Forward(input *nn.Matrix) *nn.Matrix {
+	_, hs := l.forward(input)
+	return l.output(hs[1:], hs[len(hs)-1:])
+}
+
+// Backward pass through time, accumulating the weight gradients if learning enabled
+func (l *SimpleRNN) Backward(input *nn.Matrix, grads *nn.Matrix) *nn.Matrix {
+	zs, hs := l.forward(input)
+	dhs, dStates := l.splitGrads(grads)
+	g := l.newGrads(input.Rows())
+	dh := dStates[0]
+	for t := l.timesteps - 1; t >= 0; t-- {
+		dh.Add(dhs[t])
+		z, h := zs[t], hs[t+1]
+		dz := dh.Copy()
+		for i := 0; i < dz.Rows(); i++ {
+			for j := 0; j < dz.Cols(); j++ {
+				dz.Set(i, j, dz.Get(i, j)*l.activator.Derivative(z.Get(i, j), h.Get(i, j)))
+			}
+		}
+		dh = l.accumulate(g, t, l.step(input, t), hs[t], dz)
+		if l.cut(t) && t > 0 {
+			dh = nn.NewMatrixLike(dh)
+		}
+	}
+	l.initialStateGrads = []*nn.Matrix{dh}
+	l.store(g)
+	return g.input
+}
+
+// NewSimpleRNNLayer create a SimpleRNN over input of shape (timesteps, features). With returnSequences
+// the output has shape (timesteps, units), otherwise it holds only the last hidden state.
+// With returnState the final hidden state is appended, flattening the output
+func NewSimpleRNNLayer(
+	units int,
+	returnSequences bool,
+	returnState bool,
+	useBias bool,
+	activator nn.Activator,
+	initializer nn.Initializer,
+	recurrentInitializer nn.Initializer,
+	biasInitializer nn.Initializer) *SimpleRNN {
+	return &SimpleRNN{
+		newRecurrent(units, 1, 1, returnSequences, returnState, useBias,
+			initializer, recurrentInitializer, biasInitializer),
+		activator,
+	}
+}
@@ -1,9 +1,11 @@
 package layers
 
 import (
+	"log"
 	"nn-go/nn"
 )
 
+// Softmax normalizes the innermost dimension of the input into probabilities
 type Softmax struct {
 	inputs  int
 	outputs int
@@ -17,24 +19,42 @@ func (l *Softmax) Outputs() int {
 	return l.outputs
 }
 
-func (l *Softmax) Init(inputs int) int {
-	l.inputs = inputs
-	return l.outputs
+func (l *Softmax) Init(input nn.Shape) nn.Shape {
+	if input.Last() != l.outputs {
+		log.Fatalf("Softmax over %d outputs cannot take input of shape %v", l.outputs, input)
+	}
+	l.inputs = input.Size()
+	return input
 }
 
 func (l *Softmax) Forward(input *nn.Matrix) *nn.Matrix {
-	return input.Softmax()
+	if input.Cols() == l.outputs {
+		return input.Softmax()
+	}
+	rows, cols := input.Shape()
+	return input.Reshape(rows*cols/l.outputs, l.outputs).Softmax().Reshape(rows, cols)
 }
 
-// Backward pass through the network, updating if learning enabled
-func (l *Softmax) Backward(input *nn.Matrix, grads *nn.Matrix, optimizer nn.Optimizer) *nn.Matrix {
-	// The softmax gradient is
-	for i := 0; i < input.Rows(); i++ {
-		//for j := 0; j < ; j++ {
-		//
-		//}
+// Backward the Jacobian-vector product of the softmax, dx_i = y_i (g_i - sum_j g_j y_j)
+func (l *Softmax) Backward(input *nn.Matrix, grads *nn.Matrix) *nn.Matrix {
+	rows, cols := input.Shape()
+	y := l.Forward(input).Reshape(rows*cols/l.outputs, l.outputs)
+	g := grads.Reshape(y.Rows(), l.outputs)
+	for i := 0; i < y.Rows(); i++ {
+		var dot float32
+		for j := 0; j < l.outputs; j++ {
+			dot += g.Get(i, j) * y.Get(i, j)
+		}
+		for j := 0; j < l.outputs; j++ {
+			y.Set(i, j, y.Get(i, j)*(g.Get(i, j)-dot))
+		}
 	}
-	return grads
+	return y.Reshape(rows, cols)
+}
+
+// IsSoftmax lets Model fuse this layer with a following nn.FusedSoftmaxLoss
+func (l *Softmax) IsSoftmax() bool {
+	return true
 }
 
 func NewSoftmaxLayer(outputs int) *Softmax {
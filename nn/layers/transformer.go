@@ -0,0 +1,148 @@
+package layers
+
+import (
+	"log"
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+)
+
+// TransformerEncoderBlock a post-norm transformer encoder block over input of shape
+// (timesteps, features):
+//
+//	x = LayerNorm(x + MultiHeadAttention(x))
+//	y = LayerNorm(x + Dense(activation(Dense(x))))
+//
+// where the feed-forward Dense layers are applied to each timestep separately
+type TransformerEncoderBlock struct {
+	features    int
+	attention   *MultiHeadAttention
+	attNorm     *LayerNormalization
+	hidden      *Dense
+	activation  *Activation
+	output      *Dense
+	outputNorm  *LayerNormalization
+	initializer nn.Initializer
+	params      []*nn.Param
+	trainable   bool
+}
+
+func (l *TransformerEncoderBlock) Init(input nn.Shape) nn.Shape {
+	l.attention.Init(input)
+	l.features = input.Last()
+	l.attNorm = NewLayerNormalizationLayer(1e-6)
+	l.attNorm.Init(input)
+	ff := l.activation.Init(l.hidden.Init(input))
+	l.output = NewDenseLayer(l.features, true, activations.Linear, l.initializer, initializers.Zero{})
+	l.output.Init(ff)
+	l.outputNorm = NewLayerNormalizationLayer(1e-6)
+	l.outputNorm.Init(input)
+	l.SetTrainable(l.trainable)
+	l.params = nil
+	for _, sub := range []struct {
+		name  string
+		layer nn.ParamLayer
+	}{
+		{"attention", l.attention},
+		{"attention_norm", l.attNorm},
+		{"hidden", l.hidden},
+		{"output", l.output},
+		{"output_norm", l.outputNorm},
+	} {
+		for _, p := range sub.layer.Params() {
+			p.Name = sub.name + "/" + p.Name
+			l.params = append(l.params, p)
+		}
+	}
+	return input
+}
+
+// transformerStep the input of each sub-layer needed for backpropagation
+type transformerStep struct {
+	residual1, attended, hidden, activated, residual2 *nn.Matrix
+}
+
+func (l *TransformerEncoderBlock) forward(input *nn.Matrix) (*nn.Matrix, *transformerStep) {
+	s := &transformerStep{}
+	s.residual1 = l.attention.Forward(input).Add(input)
+	s.attended = l.attNorm.Forward(s.residual1)
+	s.hidden = l.hidden.Forward(s.attended)
+	s.activated = l.activation.Forward(s.hidden)
+	s.residual2 = l.output.Forward(s.activated).Add(s.attended)
+	return l.outputNorm.Forward(s.residual2), s
+}
+
+func (l *TransformerEncoderBlock) Forward(input *nn.Matrix) *nn.Matrix {
+	output, _ := l.forward(input)
+	return output
+}
+
+// Backward pass through the network, accumulating the weight gradients of every sub-layer
+func (l *TransformerEncoderBlock) Backward(input *nn.Matrix, grads *nn.Matrix) *nn.Matrix {
+	_, s := l.forward(input)
+	dResidual2 := l.outputNorm.Backward(s.residual2, grads)
+	dActivated := l.output.Backward(s.activated, dResidual2)
+	dHidden := l.activation.Backward(s.hidden, dActivated)
+	dAttended := l.hidden.Backward(s.attended, dHidden).Add(dResidual2)
+	dResidual1 := l.attNorm.Backward(s.residual1, dAttended)
+	return l.attention.Backward(input, dResidual1).Add(dResidual1)
+}
+
+// Params the parameters of the attention, normalization and feed-forward sub-layers,
+// with their names prefixed by the sub-layer's
+func (l *TransformerEncoderBlock) Params() []*nn.Param {
+	return l.params
+}
+
+// SetTrainable freeze the weights of every sub-layer when false, Backward still returns the input gradient
+func (l *TransformerEncoderBlock) SetTrainable(trainable bool) {
+	l.trainable = trainable
+	for _, sub := range l.trainableLayers() {
+		sub.SetTrainable(trainable)
+	}
+}
+
+// Trainable whether Backward accumulates the gradients of the block's weights
+func (l *TransformerEncoderBlock) Trainable() bool {
+	return l.trainable
+}
+
+func (l *TransformerEncoderBlock) trainableLayers() []nn.TrainableLayer {
+	return []nn.TrainableLayer{l.attention, l.attNorm, l.hidden, l.output, l.outputNorm}
+}
+
+// Attention the block's attention layer, e.g. to inspect its weights or set a padding mask
+func (l *TransformerEncoderBlock) Attention() *MultiHeadAttention {
+	return l.attention
+}
+
+// Inputs the number of features in each timestep
+func (l *TransformerEncoderBlock) Inputs() int {
+	return l.features
+}
+
+// Outputs the number of features in each timestep
+func (l *TransformerEncoderBlock) Outputs() int {
+	return l.features
+}
+
+// NewTransformerEncoderBlock create an encoder block with heads attention heads of keyDim and a
+// feed-forward network of ffUnits hidden units using activator, typically ReLU or GELU
+func NewTransformerEncoderBlock(
+	heads int,
+	keyDim int,
+	ffUnits int,
+	causal bool,
+	activator nn.Activator,
+	initializer nn.Initializer) *TransformerEncoderBlock {
+	if ffUnits < 1 {
+		log.Fatalf("Feed-forward units must be more than 1, got %d\n", ffUnits)
+	}
+	return &TransformerEncoderBlock{
+		attention:   NewMultiHeadAttentionLayer(heads, keyDim, causal, initializer, initializers.Zero{}),
+		hidden:      NewDenseLayer(ffUnits, true, activations.Linear, initializer, initializers.Zero{}),
+		activation:  NewActivationLayer(activator),
+		initializer: initializer,
+		trainable:   true,
+	}
+}
@@ -1,6 +1,72 @@
 package nn
 
+import "log"
+
+// Loss compares a batch of observed outputs with the expected ones. weights is an optional
+// (batch, 1) column weighting each sample's contribution to both the loss and its gradient,
+// nil weighs every sample equally
 type Loss interface {
-	Call(observed *Matrix, expected *Matrix) *Matrix
-	Gradient(loss *Matrix, expected *Matrix) *Matrix
+	Call(observed *Matrix, expected *Matrix, weights *Matrix) *Matrix
+	Gradient(observed *Matrix, expected *Matrix, weights *Matrix) *Matrix
+}
+
+// FusedSoftmaxLoss is implemented by losses on probabilities that have a numerically
+// stable version taking the logits before the softmax instead
+type FusedSoftmaxLoss interface {
+	Loss
+	FuseSoftmax() Loss
+}
+
+// Reduction how a loss combines the losses of the samples in a batch
+type Reduction int
+
+const (
+	ReduceMean Reduction = iota // A 1x1 matrix of the mean loss, the default
+	ReduceSum                   // A 1x1 matrix of the summed loss
+	ReduceNone                  // A (batch, 1) column of the loss of each sample, Train reports their sum
+)
+
+// Reduce the (batch, 1) column of per-sample losses, first multiplying each by its weight
+// when weights is given. The weighted mean still divides by the batch size
+func (r Reduction) Reduce(losses *Matrix, weights *Matrix) *Matrix {
+	if weights != nil {
+		losses = losses.Copy().Mult(checkWeights(weights, losses.rows))
+	}
+	switch r {
+	case ReduceSum:
+		return NewMatrix(1, 1).Fill(losses.Sum())
+	case ReduceNone:
+		return losses
+	}
+	return NewMatrix(1, 1).Fill(losses.Mean())
+}
+
+// Scale turn the gradients of each sample's own loss into the gradient of the reduced loss, in-place.
+// With ReduceNone the gradients are left as they are, as if the losses were summed
+func (r Reduction) Scale(grads *Matrix, weights *Matrix) *Matrix {
+	if weights != nil {
+		checkWeights(weights, grads.rows)
+		for i := 0; i < grads.rows; i++ {
+			for j := 0; j < grads.cols; j++ {
+				grads.v[i][j] *= weights.v[i][0]
+			}
+		}
+	}
+	if r == ReduceMean {
+		return grads.Divn(float32(grads.rows))
+	}
+	return grads
+}
+
+// totalLoss the loss a Loss.Call result stands for. A ReduceNone column is summed, as the gradient
+// of each sample's loss is left unscaled
+func totalLoss(losses *Matrix) float32 {
+	return losses.Sum()
+}
+
+func checkWeights(weights *Matrix, rows int) *Matrix {
+	if weights.rows != rows || weights.cols != 1 {
+		log.Fatalf("Sample weights must be a (%d, 1) column, got (%d, %d)", rows, weights.rows, weights.cols)
+	}
+	return weights
 }
@@ -0,0 +1,111 @@
+package loss
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+)
+
+// BinaryCrossEntropy -(y ln(p) + (1 - y) ln(1 - p)) averaged over the outputs of each sample,
+// for independent probabilities p, e.g. from a sigmoid
+type BinaryCrossEntropy struct {
+	Reduction nn.Reduction
+}
+
+func binaryCrossEntropy(p float32, y float32) (float32, float32) {
+	p = clip(p)
+	return -(y*math.Log(p) + (1-y)*math.Log(1-p)), (p - y) / (p * (1 - p))
+}
+
+// Call will return the weighted losses for the batch, reduced according to b.Reduction
+func (b *BinaryCrossEntropy) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	losses, _ := meanOverValues(observed, expected, binaryCrossEntropy)
+	return b.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to the observed probabilities
+func (b *BinaryCrossEntropy) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	_, grads := meanOverValues(observed, expected, binaryCrossEntropy)
+	return b.Reduction.Scale(grads, weights)
+}
+
+// BinaryCrossEntropyWithLogits BinaryCrossEntropy of the sigmoid of observed logits,
+// computed directly from the logits so it is stable for large values
+type BinaryCrossEntropyWithLogits struct {
+	Reduction nn.Reduction
+}
+
+// binaryCrossEntropyLogits max(x, 0) - xy + ln(1 + e^-|x|)
+func binaryCrossEntropyLogits(x float32, y float32) (float32, float32) {
+	sigmoid := 1 / (1 + math.Exp(-x))
+	return math.Max(x, 0) - x*y + math.Log1p(math.Exp(-math.Abs(x))), sigmoid - y
+}
+
+// Call will return the weighted losses for the batch, reduced according to b.Reduction
+func (b *BinaryCrossEntropyWithLogits) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	losses, _ := meanOverValues(observed, expected, binaryCrossEntropyLogits)
+	return b.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to the logits
+func (b *BinaryCrossEntropyWithLogits) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	_, grads := meanOverValues(observed, expected, binaryCrossEntropyLogits)
+	return b.Reduction.Scale(grads, weights)
+}
+
+// signedLabel labels are expected to be -1 or 1, 0 is treated as -1
+func signedLabel(y float32) float32 {
+	if y == 0 {
+		return -1
+	}
+	return y
+}
+
+// Hinge mean(max(0, 1 - y p)) for labels of -1 or 1 (0 is treated as -1)
+type Hinge struct {
+	Reduction nn.Reduction
+}
+
+func hinge(p float32, y float32) (float32, float32) {
+	y = signedLabel(y)
+	if margin := 1 - y*p; margin > 0 {
+		return margin, -y
+	}
+	return 0, 0
+}
+
+// Call will return the weighted losses for the batch, reduced according to h.Reduction
+func (h *Hinge) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	losses, _ := meanOverValues(observed, expected, hinge)
+	return h.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to observed
+func (h *Hinge) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	_, grads := meanOverValues(observed, expected, hinge)
+	return h.Reduction.Scale(grads, weights)
+}
+
+// SquaredHinge mean(max(0, 1 - y p)^2) for labels of -1 or 1 (0 is treated as -1)
+type SquaredHinge struct {
+	Reduction nn.Reduction
+}
+
+func squaredHinge(p float32, y float32) (float32, float32) {
+	y = signedLabel(y)
+	if margin := 1 - y*p; margin > 0 {
+		return margin * margin, -2 * y * margin
+	}
+	return 0, 0
+}
+
+// Call will return the weighted losses for the batch, reduced according to h.Reduction
+func (h *SquaredHinge) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	losses, _ := meanOverValues(observed, expected, squaredHinge)
+	return h.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to observed
+func (h *SquaredHinge) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	_, grads := meanOverValues(observed, expected, squaredHinge)
+	return h.Reduction.Scale(grads, weights)
+}
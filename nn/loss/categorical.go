@@ -0,0 +1,109 @@
+package loss
+
+import (
+	math "github.com/chewxy/math32"
+	"log"
+	"nn-go/nn"
+)
+
+// LabelSmoothingCrossEntropy CategoricalCrossEntropy against one-hot labels softened to
+// y(1 - smoothing) + smoothing/classes, so the model isn't pushed towards overconfident
+// predictions on noisy labels
+type LabelSmoothingCrossEntropy struct {
+	smoothing float32
+	Reduction nn.Reduction
+}
+
+func NewLabelSmoothingCrossEntropy(smoothing float32) *LabelSmoothingCrossEntropy {
+	if smoothing < 0 || smoothing >= 1 {
+		log.Fatalf("Label smoothing must be in [0, 1), got %f", smoothing)
+	}
+	return &LabelSmoothingCrossEntropy{smoothing: smoothing}
+}
+
+// smooth the expected labels
+func (l *LabelSmoothingCrossEntropy) smooth(expected *nn.Matrix) *nn.Matrix {
+	return expected.Copy().Multn(1 - l.smoothing).Addn(l.smoothing / float32(expected.Cols()))
+}
+
+// Call will return the weighted losses for the batch, reduced according to l.Reduction
+func (l *LabelSmoothingCrossEntropy) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	return (&CategoricalCrossEntropy{l.Reduction}).Call(observed, l.smooth(expected), weights)
+}
+
+// Gradient of the reduced loss with respect to the observed probabilities
+func (l *LabelSmoothingCrossEntropy) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	return (&CategoricalCrossEntropy{l.Reduction}).Gradient(observed, l.smooth(expected), weights)
+}
+
+// FuseSoftmax computes the loss from the logits when the model ends in a softmax
+func (l *LabelSmoothingCrossEntropy) FuseSoftmax() nn.Loss {
+	return &smoothedSoftmaxCrossEntropy{l}
+}
+
+// smoothedSoftmaxCrossEntropy LabelSmoothingCrossEntropy of the softmax of observed logits
+type smoothedSoftmaxCrossEntropy struct {
+	smoothed *LabelSmoothingCrossEntropy
+}
+
+func (s *smoothedSoftmaxCrossEntropy) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	return (&SoftmaxCrossEntropy{s.smoothed.Reduction}).Call(observed, s.smoothed.smooth(expected), weights)
+}
+
+func (s *smoothedSoftmaxCrossEntropy) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	return (&SoftmaxCrossEntropy{s.smoothed.Reduction}).Gradient(observed, s.smoothed.smooth(expected), weights)
+}
+
+// Focal -sum(alpha * y * (1 - p)^gamma * ln(p)) of probabilities p against one-hot labels y.
+// The (1 - p)^gamma factor down-weights samples that are already classified well, so training
+// concentrates on the hard ones. With gamma 0 and alpha 1 it is CategoricalCrossEntropy
+type Focal struct {
+	gamma     float32
+	alpha     float32
+	Reduction nn.Reduction
+}
+
+// NewFocalLoss create a focal loss, the original paper uses gamma 2 and alpha 0.25
+func NewFocalLoss(gamma float32, alpha float32) *Focal {
+	if gamma < 0 {
+		log.Fatalf("Focal gamma cannot be negative, got %f", gamma)
+	}
+	if alpha <= 0 {
+		log.Fatalf("Focal alpha must be positive, got %f", alpha)
+	}
+	return &Focal{gamma: gamma, alpha: alpha}
+}
+
+func (f *Focal) losses(observed *nn.Matrix, expected *nn.Matrix) (*nn.Matrix, *nn.Matrix) {
+	checkShapes(observed, expected)
+	rows, classes := expected.Shape()
+	losses := nn.NewMatrix(rows, 1)
+	grads := nn.NewMatrixLike(observed)
+	for i := 0; i < rows; i++ {
+		var sum float32
+		for j := 0; j < classes; j++ {
+			p, y := clip(observed.Get(i, j)), expected.Get(i, j)
+			if y == 0 {
+				continue
+			}
+			modulator := math.Pow(1-p, f.gamma)
+			sum += f.alpha * y * modulator * math.Log(p)
+			// d/dp -(1-p)^gamma ln(p) = gamma (1-p)^(gamma-1) ln(p) - (1-p)^gamma / p
+			grads.Set(i, j, f.alpha*y*(f.gamma*math.Pow(1-p, f.gamma-1)*math.Log(p)-modulator/p))
+		}
+		losses.Set(i, 0, -sum)
+	}
+	return losses, grads
+}
+
+// Call will return the weighted losses for the batch, reduced according to f.Reduction
+func (f *Focal) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	losses, _ := f.losses(observed, expected)
+	return f.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to the observed probabilities
+func (f *Focal) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	_, grads := f.losses(observed, expected)
+	return f.Reduction.Scale(grads, weights)
+}
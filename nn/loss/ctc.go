@@ -0,0 +1,349 @@
+package loss
+
+import (
+	"fmt"
+	math "github.com/chewxy/math32"
+	"log"
+	"nn-go/nn"
+	"sort"
+)
+
+// ctcPadding pads the label sequences of CTC labels to the same length
+const ctcPadding = -1
+
+// CTC Connectionist Temporal Classification, -ln of the probability of the label sequence summed
+// over every alignment of it to the input, so the alignment doesn't need to be known.
+//
+// observed holds unnormalised logits of shape (timesteps, classes) for each sample, the log-softmax
+// is taken over the classes of each timestep. expected is built by CTCLabels: the first column holds
+// how many timesteps of each sample are real input and the rest its labels, padded with -1
+type CTC struct {
+	classes   int
+	blank     int
+	Reduction nn.Reduction
+}
+
+// NewCTCLoss create a CTC loss over classes classes, including the blank class at index blank
+func NewCTCLoss(classes int, blank int) *CTC {
+	if classes < 2 {
+		log.Fatalf("CTC needs at least 2 classes including the blank, got %d", classes)
+	}
+	if blank < 0 || blank >= classes {
+		log.Fatalf("CTC blank index must be in [0, %d), got %d", classes, blank)
+	}
+	return &CTC{classes: classes, blank: blank}
+}
+
+// CTCLabels pack the number of real input timesteps and the label sequence of each sample into the
+// expected matrix of a CTC loss. Labels may have different lengths and must not contain the blank
+func CTCLabels(inputLengths []int, labels [][]int) *nn.Matrix {
+	if len(inputLengths) != len(labels) {
+		log.Fatalf("Got %d input lengths for %d label sequences", len(inputLengths), len(labels))
+	}
+	width := 0
+	for _, l := range labels {
+		if len(l) > width {
+			width = len(l)
+		}
+	}
+	out := nn.NewMatrix(len(labels), width+1).Fill(ctcPadding)
+	for i, l := range labels {
+		if inputLengths[i] < 1 {
+			log.Fatalf("Input length of sample %d must be positive, got %d", i, inputLengths[i])
+		}
+		out.Set(i, 0, float32(inputLengths[i]))
+		for j, c := range l {
+			out.Set(i, j+1, float32(c))
+		}
+	}
+	return out
+}
+
+// timesteps the number of timesteps in each sample of observed
+func (c *CTC) timesteps(observed *nn.Matrix) int {
+	if observed.Cols()%c.classes != 0 {
+		log.Fatalf("CTC expects (timesteps, %d) logits, got %d cols", c.classes, observed.Cols())
+	}
+	return observed.Cols() / c.classes
+}
+
+// sample the number of real timesteps and the labels of sample i
+func (c *CTC) sample(expected *nn.Matrix, i int, timesteps int) (int, []int) {
+	length := int(expected.Get(i, 0))
+	if length < 1 || length > timesteps {
+		log.Fatalf("Input length of sample %d must be in [1, %d], got %d", i, timesteps, length)
+	}
+	var labels []int
+	for j := 1; j < expected.Cols() && expected.Get(i, j) != ctcPadding; j++ {
+		l := int(expected.Get(i, j))
+		if l < 0 || l >= c.classes || l == c.blank {
+			log.Fatalf("Label %v of sample %d is not a non-blank class index", expected.Get(i, j), i)
+		}
+		labels = append(labels, l)
+	}
+	// Repeated labels need a blank between them, so each takes an extra timestep
+	required := len(labels)
+	for j := 1; j < len(labels); j++ {
+		if labels[j] == labels[j-1] {
+			required++
+		}
+	}
+	if required > length {
+		log.Fatalf("Sample %d needs at least %d timesteps to emit its labels but has %d", i, required, length)
+	}
+	return length, labels
+}
+
+// logProbs the log-softmax of each timestep of sample i as a (timesteps, classes) matrix
+func (c *CTC) logProbs(observed *nn.Matrix, i int, timesteps int) *nn.Matrix {
+	return logSoftmax(observed.SliceRows(i, i+1).Reshape(timesteps, c.classes))
+}
+
+// logAdd ln(e^a + e^b) without leaving log-space
+func logAdd(a float32, b float32) float32 {
+	if math.IsInf(a, -1) {
+		return b
+	}
+	if math.IsInf(b, -1) {
+		return a
+	}
+	if a < b {
+		a, b = b, a
+	}
+	return a + math.Log1p(math.Exp(b-a))
+}
+
+// extend interleave the labels with blanks, b l1 b l2 ... b
+func (c *CTC) extend(labels []int) []int {
+	extended := make([]int, 2*len(labels)+1)
+	for s := range extended {
+		extended[s] = c.blank
+		if s%2 == 1 {
+			extended[s] = labels[s/2]
+		}
+	}
+	return extended
+}
+
+// logMatrix a rows x cols table filled with ln(0)
+func logMatrix(rows int, cols int) [][]float32 {
+	out := make([][]float32, rows)
+	for i := range out {
+		out[i] = make([]float32, cols)
+		for j := range out[i] {
+			out[i][j] = math.Inf(-1)
+		}
+	}
+	return out
+}
+
+// skip whether a path may jump from extended label s-2 straight to s, which is
+// allowed unless s is a blank or repeats the label before the blank in between
+func skip(extended []int, s int) bool {
+	return s >= 2 && extended[s] != extended[s-2]
+}
+
+// forwardBackward the log forward and backward variables of the extended labels over the
+// first length timesteps, both including the emission at their timestep, and ln p(labels | input)
+func (c *CTC) forwardBackward(logProbs *nn.Matrix, extended []int, length int) ([][]float32, [][]float32, float32) {
+	states := len(extended)
+	alpha, beta := logMatrix(length, states), logMatrix(length, states)
+	alpha[0][0] = logProbs.Get(0, extended[0])
+	if states > 1 {
+		alpha[0][1] = logProbs.Get(0, extended[1])
+	}
+	for t := 1; t < length; t++ {
+		for s := 0; s < states; s++ {
+			a := alpha[t-1][s]
+			if s >= 1 {
+				a = logAdd(a, alpha[t-1][s-1])
+			}
+			if skip(extended, s) {
+				a = logAdd(a, alpha[t-1][s-2])
+			}
+			alpha[t][s] = a + logProbs.Get(t, extended[s])
+		}
+	}
+	last := length - 1
+	beta[last][states-1] = logProbs.Get(last, extended[states-1])
+	if states > 1 {
+		beta[last][states-2] = logProbs.Get(last, extended[states-2])
+	}
+	for t := last - 1; t >= 0; t-- {
+		for s := 0; s < states; s++ {
+			b := beta[t+1][s]
+			if s+1 < states {
+				b = logAdd(b, beta[t+1][s+1])
+			}
+			if s+2 < states && skip(extended, s+2) {
+				b = logAdd(b, beta[t+1][s+2])
+			}
+			beta[t][s] = b + logProbs.Get(t, extended[s])
+		}
+	}
+	logLikelihood := alpha[last][states-1]
+	if states > 1 {
+		logLikelihood = logAdd(logLikelihood, alpha[last][states-2])
+	}
+	return alpha, beta, logLikelihood
+}
+
+// Call will return the weighted losses for the batch, reduced according to c.Reduction
+func (c *CTC) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	timesteps := c.timesteps(observed)
+	losses := nn.NewMatrix(observed.Rows(), 1)
+	for i := 0; i < observed.Rows(); i++ {
+		length, labels := c.sample(expected, i, timesteps)
+		_, _, logLikelihood := c.forwardBackward(c.logProbs(observed, i, timesteps), c.extend(labels), length)
+		losses.Set(i, 0, -logLikelihood)
+	}
+	return c.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to the logits, softmax(x_t) minus the posterior
+// probability of each class at timestep t. Timesteps past the input length get no gradient
+func (c *CTC) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	timesteps := c.timesteps(observed)
+	grads := nn.NewMatrixLike(observed)
+	for i := 0; i < observed.Rows(); i++ {
+		length, labels := c.sample(expected, i, timesteps)
+		logProbs := c.logProbs(observed, i, timesteps)
+		extended := c.extend(labels)
+		alpha, beta, logLikelihood := c.forwardBackward(logProbs, extended, length)
+		for t := 0; t < length; t++ {
+			// alpha and beta both include the emission at t, so it is counted twice
+			posterior := make([]float32, c.classes)
+			for k := range posterior {
+				posterior[k] = math.Inf(-1)
+			}
+			for s, k := range extended {
+				posterior[k] = logAdd(posterior[k], alpha[t][s]+beta[t][s])
+			}
+			for k, p := range posterior {
+				lp := logProbs.Get(t, k)
+				grads.Set(i, t*c.classes+k, math.Exp(lp)-math.Exp(p-lp-logLikelihood))
+			}
+		}
+	}
+	return c.Reduction.Scale(grads, weights)
+}
+
+// ctcLengths the number of timesteps to decode in each sample, all of them when inputLengths is nil
+func ctcLengths(inputLengths []int, batch int, timesteps int) []int {
+	if inputLengths == nil {
+		lengths := make([]int, batch)
+		for i := range lengths {
+			lengths[i] = timesteps
+		}
+		return lengths
+	}
+	if len(inputLengths) != batch {
+		log.Fatalf("Got %d input lengths for a batch of %d", len(inputLengths), batch)
+	}
+	for i, l := range inputLengths {
+		if l < 1 || l > timesteps {
+			log.Fatalf("Input length of sample %d must be in [1, %d], got %d", i, timesteps, l)
+		}
+	}
+	return inputLengths
+}
+
+// GreedyDecode the most likely class at each timestep with repeats merged and blanks removed.
+// inputLengths gives how many timesteps of each sample to decode, nil decodes all of them
+func (c *CTC) GreedyDecode(observed *nn.Matrix, inputLengths []int) [][]int {
+	timesteps := c.timesteps(observed)
+	lengths := ctcLengths(inputLengths, observed.Rows(), timesteps)
+	out := make([][]int, observed.Rows())
+	for i := range out {
+		out[i] = []int{}
+		previous := c.blank
+		for t := 0; t < lengths[i]; t++ {
+			best := 0
+			for k := 1; k < c.classes; k++ {
+				if observed.Get(i, t*c.classes+k) > observed.Get(i, t*c.classes+best) {
+					best = k
+				}
+			}
+			if best != c.blank && best != previous {
+				out[i] = append(out[i], best)
+			}
+			previous = best
+		}
+	}
+	return out
+}
+
+// beam a labelling being decoded with the log probabilities of the alignments
+// of it so far that end in a blank and that end in its last label
+type beam struct {
+	labels   []int
+	blank    float32
+	nonBlank float32
+}
+
+func (b *beam) total() float32 {
+	return logAdd(b.blank, b.nonBlank)
+}
+
+// BeamSearchDecode the most likely labelling found by CTC prefix beam search, which keeps the
+// beamWidth most likely labellings at each timestep summed over all of their alignments.
+// inputLengths gives how many timesteps of each sample to decode, nil decodes all of them
+func (c *CTC) BeamSearchDecode(observed *nn.Matrix, inputLengths []int, beamWidth int) [][]int {
+	if beamWidth < 1 {
+		log.Fatalf("Beam width must be at least 1, got %d", beamWidth)
+	}
+	timesteps := c.timesteps(observed)
+	lengths := ctcLengths(inputLengths, observed.Rows(), timesteps)
+	out := make([][]int, observed.Rows())
+	for i := range out {
+		logProbs := c.logProbs(observed, i, timesteps)
+		beams := []*beam{{labels: []int{}, blank: 0, nonBlank: math.Inf(-1)}}
+		for t := 0; t < lengths[i]; t++ {
+			next := map[string]*beam{}
+			extend := func(labels []int) *beam {
+				key := fmt.Sprint(labels)
+				if b, ok := next[key]; ok {
+					return b
+				}
+				b := &beam{labels: labels, blank: math.Inf(-1), nonBlank: math.Inf(-1)}
+				next[key] = b
+				return b
+			}
+			for _, b := range beams {
+				for k := 0; k < c.classes; k++ {
+					p := logProbs.Get(t, k)
+					if k == c.blank {
+						same := extend(b.labels)
+						same.blank = logAdd(same.blank, b.total()+p)
+						continue
+					}
+					labels := append(append([]int{}, b.labels...), k)
+					longer := extend(labels)
+					if len(b.labels) > 0 && b.labels[len(b.labels)-1] == k {
+						// A repeat only extends the labelling after a blank, otherwise it merges
+						longer.nonBlank = logAdd(longer.nonBlank, b.blank+p)
+						same := extend(b.labels)
+						same.nonBlank = logAdd(same.nonBlank, b.nonBlank+p)
+					} else {
+						longer.nonBlank = logAdd(longer.nonBlank, b.total()+p)
+					}
+				}
+			}
+			beams = beams[:0]
+			for _, b := range next {
+				beams = append(beams, b)
+			}
+			sort.Slice(beams, func(a, b int) bool {
+				if beams[a].total() != beams[b].total() {
+					return beams[a].total() > beams[b].total()
+				}
+				return fmt.Sprint(beams[a].labels) < fmt.Sprint(beams[b].labels)
+			})
+			if len(beams) > beamWidth {
+				beams = beams[:beamWidth]
+			}
+		}
+		out[i] = beams[0].labels
+	}
+	return out
+}
@@ -0,0 +1,361 @@
+package loss
+
+import (
+	math "github.com/chewxy/math32"
+	"log"
+	"nn-go/nn"
+)
+
+// The embedding losses compare the rows of observed, one embedding per sample, with each other.
+// expected is a (batch, 1) column holding an identity for each sample: samples with the same
+// identity should be embedded close together and samples with different ones far apart.
+// Every sample's loss depends on the rest of the batch, so batches should hold several
+// samples of each identity
+
+// coefficients how much the loss of each sample counts towards the reduced loss
+func coefficients(reduction nn.Reduction, weights *nn.Matrix, batch int) *nn.Matrix {
+	return reduction.Scale(nn.NewMatrix(batch, 1).Fill(1), weights)
+}
+
+// identities the identity of each sample
+func identities(observed *nn.Matrix, expected *nn.Matrix) []int {
+	if expected.Cols() != 1 || expected.Rows() != observed.Rows() {
+		log.Fatalf("Identities must be a (%d, 1) column, got (%d, %d)", observed.Rows(), expected.Rows(), expected.Cols())
+	}
+	out := make([]int, expected.Rows())
+	for i := range out {
+		out[i] = int(expected.Get(i, 0))
+	}
+	return out
+}
+
+// distances the euclidean distance between every pair of rows
+func distances(observed *nn.Matrix) [][]float32 {
+	n := observed.Rows()
+	out := make([][]float32, n)
+	for i := range out {
+		out[i] = make([]float32, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			var sum float32
+			for k := 0; k < observed.Cols(); k++ {
+				d := observed.Get(i, k) - observed.Get(j, k)
+				sum += d * d
+			}
+			out[i][j] = math.Sqrt(sum)
+			out[j][i] = out[i][j]
+		}
+	}
+	return out
+}
+
+// addDistanceGrad backpropagate g, the gradient of the distance d between rows i and j, to the rows
+func addDistanceGrad(grads *nn.Matrix, observed *nn.Matrix, i int, j int, d float32, g float32) {
+	if d == 0 || g == 0 {
+		return
+	}
+	for k := 0; k < observed.Cols(); k++ {
+		v := g * (observed.Get(i, k) - observed.Get(j, k)) / d
+		grads.Set(i, k, grads.Get(i, k)+v)
+		grads.Set(j, k, grads.Get(j, k)-v)
+	}
+}
+
+// Contrastive d^2 for pairs of samples with the same identity and max(0, margin - d)^2 for pairs with
+// different ones, where d is the euclidean distance between their embeddings. Every pair in the batch
+// is used and each sample's loss is the mean over the pairs it is in
+type Contrastive struct {
+	margin    float32
+	Reduction nn.Reduction
+}
+
+func NewContrastiveLoss(margin float32) *Contrastive {
+	if margin <= 0 {
+		log.Fatalf("Contrastive margin must be positive, got %f", margin)
+	}
+	return &Contrastive{margin: margin}
+}
+
+// pair the loss of a pair at distance d and its gradient with respect to d
+func (c *Contrastive) pair(d float32, similar bool) (float32, float32) {
+	if similar {
+		return d * d, 2 * d
+	}
+	if d >= c.margin {
+		return 0, 0
+	}
+	return (c.margin - d) * (c.margin - d), -2 * (c.margin - d)
+}
+
+func (c *Contrastive) losses(observed *nn.Matrix, expected *nn.Matrix, coefficients *nn.Matrix) (*nn.Matrix, *nn.Matrix) {
+	ids := identities(observed, expected)
+	n := observed.Rows()
+	if n < 2 {
+		log.Fatalf("Contrastive loss needs at least 2 samples in a batch, got %d", n)
+	}
+	d := distances(observed)
+	losses := nn.NewMatrix(n, 1)
+	grads := nn.NewMatrixLike(observed)
+	for i := 0; i < n; i++ {
+		var sum float32
+		for j := 0; j < n; j++ {
+			if i == j {
+				continue
+			}
+			l, g := c.pair(d[i][j], ids[i] == ids[j])
+			sum += l
+			if coefficients != nil {
+				addDistanceGrad(grads, observed, i, j, d[i][j], coefficients.Get(i, 0)*g/float32(n-1))
+			}
+		}
+		losses.Set(i, 0, sum/float32(n-1))
+	}
+	return losses, grads
+}
+
+// Call will return the weighted losses for the batch, reduced according to c.Reduction
+func (c *Contrastive) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	losses, _ := c.losses(observed, expected, nil)
+	return c.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to the embeddings
+func (c *Contrastive) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	_, grads := c.losses(observed, expected, coefficients(c.Reduction, weights, observed.Rows()))
+	return grads
+}
+
+// TripletMining how Triplet picks the negative for each anchor and positive from the batch
+type TripletMining int
+
+const (
+	// BatchHard pairs each anchor's furthest positive with its closest negative
+	BatchHard TripletMining = iota
+	// SemiHard uses every positive of the anchor, each with the closest negative that is further
+	// from the anchor than the positive, or the furthest negative when there is none
+	SemiHard
+)
+
+// Triplet max(0, d(a, p) - d(a, n) + margin) for anchors a, positives p with the same identity and
+// negatives n with a different one, mined from the batch. Each sample is used as the anchor of its loss,
+// which is the mean over its triplets. Samples with no positive or no negative in the batch have no loss
+type Triplet struct {
+	margin    float32
+	mining    TripletMining
+	Reduction nn.Reduction
+}
+
+func NewTripletLoss(margin float32, mining TripletMining) *Triplet {
+	if margin <= 0 {
+		log.Fatalf("Triplet margin must be positive, got %f", margin)
+	}
+	if mining != BatchHard && mining != SemiHard {
+		log.Fatalf("Unknown triplet mining %d", mining)
+	}
+	return &Triplet{margin: margin, mining: mining}
+}
+
+// triplets the positive and negative of each triplet of anchor a
+func (t *Triplet) triplets(d [][]float32, ids []int, a int) [][2]int {
+	var positives, negatives []int
+	for j := range ids {
+		if j == a {
+			continue
+		}
+		if ids[j] == ids[a] {
+			positives = append(positives, j)
+		} else {
+			negatives = append(negatives, j)
+		}
+	}
+	if len(positives) == 0 || len(negatives) == 0 {
+		return nil
+	}
+	closest, furthest := negatives[0], negatives[0]
+	for _, n := range negatives {
+		if d[a][n] < d[a][closest] {
+			closest = n
+		}
+		if d[a][n] > d[a][furthest] {
+			furthest = n
+		}
+	}
+	if t.mining == BatchHard {
+		hardest := positives[0]
+		for _, p := range positives {
+			if d[a][p] > d[a][hardest] {
+				hardest = p
+			}
+		}
+		return [][2]int{{hardest, closest}}
+	}
+	var out [][2]int
+	for _, p := range positives {
+		negative := -1
+		for _, n := range negatives {
+			if d[a][n] > d[a][p] && (negative < 0 || d[a][n] < d[a][negative]) {
+				negative = n
+			}
+		}
+		if negative < 0 {
+			negative = furthest
+		}
+		out = append(out, [2]int{p, negative})
+	}
+	return out
+}
+
+func (t *Triplet) losses(observed *nn.Matrix, expected *nn.Matrix, coefficients *nn.Matrix) (*nn.Matrix, *nn.Matrix) {
+	ids := identities(observed, expected)
+	d := distances(observed)
+	losses := nn.NewMatrix(observed.Rows(), 1)
+	grads := nn.NewMatrixLike(observed)
+	for a := range ids {
+		triplets := t.triplets(d, ids, a)
+		var sum float32
+		for _, triplet := range triplets {
+			p, n := triplet[0], triplet[1]
+			l := d[a][p] - d[a][n] + t.margin
+			if l <= 0 {
+				continue
+			}
+			sum += l
+			if coefficients != nil {
+				g := coefficients.Get(a, 0) / float32(len(triplets))
+				addDistanceGrad(grads, observed, a, p, d[a][p], g)
+				addDistanceGrad(grads, observed, a, n, d[a][n], -g)
+			}
+		}
+		if len(triplets) > 0 {
+			losses.Set(a, 0, sum/float32(len(triplets)))
+		}
+	}
+	return losses, grads
+}
+
+// Call will return the weighted losses for the batch, reduced according to t.Reduction
+func (t *Triplet) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	losses, _ := t.losses(observed, expected, nil)
+	return t.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to the embeddings, holding the mined triplets fixed
+func (t *Triplet) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	_, grads := t.losses(observed, expected, coefficients(t.Reduction, weights, observed.Rows()))
+	return grads
+}
+
+// NTXent the normalized temperature-scaled cross-entropy, or InfoNCE, over cosine similarities.
+// Each sample is an anchor whose loss is the mean over its positives p of
+//
+//	-ln(e^(s(a, p) / temperature) / sum_(k != a) e^(s(a, k) / temperature))
+//
+// where every other sample in the batch is a negative. With two augmented views of each
+// image sharing an identity this is the SimCLR loss. Samples with no positive have no loss
+type NTXent struct {
+	temperature float32
+	Reduction   nn.Reduction
+}
+
+func NewNTXentLoss(temperature float32) *NTXent {
+	if temperature <= 0 {
+		log.Fatalf("NT-Xent temperature must be positive, got %f", temperature)
+	}
+	return &NTXent{temperature: temperature}
+}
+
+// normalize each row to unit length, returning the normalized rows and their original lengths
+func normalize(observed *nn.Matrix) (*nn.Matrix, []float32) {
+	out := observed.Copy()
+	norms := make([]float32, out.Rows())
+	for i := range norms {
+		var sum float32
+		for k := 0; k < out.Cols(); k++ {
+			sum += out.Get(i, k) * out.Get(i, k)
+		}
+		norms[i] = math.Max(math.Sqrt(sum), epsilon)
+		for k := 0; k < out.Cols(); k++ {
+			out.Set(i, k, out.Get(i, k)/norms[i])
+		}
+	}
+	return out, norms
+}
+
+func (x *NTXent) losses(observed *nn.Matrix, expected *nn.Matrix, coefficients *nn.Matrix) (*nn.Matrix, *nn.Matrix) {
+	ids := identities(observed, expected)
+	z, norms := normalize(observed)
+	logits := z.Product(z.T()).Multn(1 / x.temperature)
+	n := observed.Rows()
+	losses := nn.NewMatrix(n, 1)
+	// Gradient with respect to the logits, then the normalized embeddings
+	dLogits := nn.NewMatrix(n, n)
+	for a := 0; a < n; a++ {
+		var positives []int
+		max := math.Inf(-1)
+		for k := 0; k < n; k++ {
+			if k == a {
+				continue
+			}
+			if ids[k] == ids[a] {
+				positives = append(positives, k)
+			}
+			max = math.Max(max, logits.Get(a, k))
+		}
+		if len(positives) == 0 {
+			continue
+		}
+		var sum float32
+		for k := 0; k < n; k++ {
+			if k != a {
+				sum += math.Exp(logits.Get(a, k) - max)
+			}
+		}
+		logSum := max + math.Log(sum)
+		var loss float32
+		for _, p := range positives {
+			loss += logSum - logits.Get(a, p)
+		}
+		losses.Set(a, 0, loss/float32(len(positives)))
+		if coefficients == nil {
+			continue
+		}
+		c := coefficients.Get(a, 0)
+		for k := 0; k < n; k++ {
+			if k != a {
+				dLogits.Set(a, k, c*math.Exp(logits.Get(a, k)-logSum))
+			}
+		}
+		for _, p := range positives {
+			dLogits.Set(a, p, dLogits.Get(a, p)-c/float32(len(positives)))
+		}
+	}
+	if coefficients == nil {
+		return losses, nil
+	}
+	// logits = z z^T / temperature, so dz = (dLogits + dLogits^T) z / temperature
+	dz := dLogits.Copy().Add(dLogits.T()).Product(z).Multn(1 / x.temperature)
+	grads := nn.NewMatrixLike(observed)
+	for i := 0; i < n; i++ {
+		var dot float32
+		for k := 0; k < observed.Cols(); k++ {
+			dot += dz.Get(i, k) * z.Get(i, k)
+		}
+		for k := 0; k < observed.Cols(); k++ {
+			grads.Set(i, k, (dz.Get(i, k)-z.Get(i, k)*dot)/norms[i])
+		}
+	}
+	return losses, grads
+}
+
+// Call will return the weighted losses for the batch, reduced according to x.Reduction
+func (x *NTXent) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	losses, _ := x.losses(observed, expected, nil)
+	return x.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to the embeddings
+func (x *NTXent) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	_, grads := x.losses(observed, expected, coefficients(x.Reduction, weights, observed.Rows()))
+	return grads
+}
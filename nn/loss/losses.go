@@ -2,31 +2,242 @@ package loss
 
 import (
 	math "github.com/chewxy/math32"
+	"log"
 	"nn-go/nn"
 )
 
-type CategoricalCrossEntropy struct{}
+// epsilon probabilities are clipped to [epsilon, 1-epsilon] before taking logs
+const epsilon = 1e-7
 
-// Call will return a column vector of losses for the batch
-func (c *CategoricalCrossEntropy) Call(observed *nn.Matrix, expected *nn.Matrix) *nn.Matrix {
+func clip(p float32) float32 {
+	return math.Max(epsilon, math.Min(1-epsilon, p))
+}
+
+// elementLoss the loss and its gradient for a single observed value p with expected value y
+type elementLoss func(p float32, y float32) (float32, float32)
+
+// meanOverValues apply fn to every value and average over each sample.
+// Returns the (batch, 1) column of losses and the gradient of each sample's loss
+func meanOverValues(observed *nn.Matrix, expected *nn.Matrix, fn elementLoss) (*nn.Matrix, *nn.Matrix) {
+	checkShapes(observed, expected)
+	rows, cols := observed.Shape()
+	losses := nn.NewMatrix(rows, 1)
+	grads := nn.NewMatrixLike(observed)
+	for i := 0; i < rows; i++ {
+		var sum float32
+		for j := 0; j < cols; j++ {
+			l, g := fn(observed.Get(i, j), expected.Get(i, j))
+			sum += l
+			grads.Set(i, j, g/float32(cols))
+		}
+		losses.Set(i, 0, sum/float32(cols))
+	}
+	return losses, grads
+}
+
+func checkShapes(observed *nn.Matrix, expected *nn.Matrix) {
+	if observed.Rows() != expected.Rows() || observed.Cols() != expected.Cols() {
+		log.Fatalf("Observed (%d, %d) and expected (%d, %d) must have the same shape",
+			observed.Rows(), observed.Cols(), expected.Rows(), expected.Cols())
+	}
+}
+
+// CategoricalCrossEntropy -sum(y * ln(p)) of probabilities p against one-hot or soft labels y
+type CategoricalCrossEntropy struct {
+	Reduction nn.Reduction
+}
+
+func (c *CategoricalCrossEntropy) losses(observed *nn.Matrix, expected *nn.Matrix) (*nn.Matrix, *nn.Matrix) {
+	checkShapes(observed, expected)
 	rows, classes := expected.Shape()
 	losses := nn.NewMatrix(rows, 1)
-	// both should be a single row of outputs
+	grads := nn.NewMatrixLike(observed)
 	for i := 0; i < rows; i++ {
 		var sum float32
 		for j := 0; j < classes; j++ {
-			sum += expected.Get(i, j) * math.Log2(observed.Get(i, j)+0.00001)
+			p, y := clip(observed.Get(i, j)), expected.Get(i, j)
+			sum += y * math.Log(p)
+			grads.Set(i, j, -y/p)
+		}
+		losses.Set(i, 0, -sum)
+	}
+	return losses, grads
+}
+
+// Call will return the weighted losses for the batch, reduced according to c.Reduction
+func (c *CategoricalCrossEntropy) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	losses, _ := c.losses(observed, expected)
+	return c.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to the observed probabilities
+func (c *CategoricalCrossEntropy) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	_, grads := c.losses(observed, expected)
+	return c.Reduction.Scale(grads, weights)
+}
+
+// FuseSoftmax computes the loss from the logits when the model ends in a softmax
+func (c *CategoricalCrossEntropy) FuseSoftmax() nn.Loss {
+	return &SoftmaxCrossEntropy{c.Reduction}
+}
+
+// SoftmaxCrossEntropy categorical cross-entropy of the softmax of observed logits.
+// The log-softmax is computed directly, so it is stable for large logits
+type SoftmaxCrossEntropy struct {
+	Reduction nn.Reduction
+}
+
+// logSoftmax of each row, x_j - max - log(sum_k e^(x_k - max))
+func logSoftmax(logits *nn.Matrix) *nn.Matrix {
+	out := logits.Copy()
+	for i := 0; i < out.Rows(); i++ {
+		max := out.Get(i, 0)
+		for j := 0; j < out.Cols(); j++ {
+			max = math.Max(max, out.Get(i, j))
+		}
+		var sum float32
+		for j := 0; j < out.Cols(); j++ {
+			sum += math.Exp(out.Get(i, j) - max)
+		}
+		logSum := max + math.Log(sum)
+		for j := 0; j < out.Cols(); j++ {
+			out.Set(i, j, out.Get(i, j)-logSum)
+		}
+	}
+	return out
+}
+
+// Call will return the weighted losses for the batch, reduced according to s.Reduction
+func (s *SoftmaxCrossEntropy) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	checkShapes(observed, expected)
+	logProbs := logSoftmax(observed)
+	losses := nn.NewMatrix(expected.Rows(), 1)
+	for i := 0; i < expected.Rows(); i++ {
+		var sum float32
+		for j := 0; j < expected.Cols(); j++ {
+			sum += expected.Get(i, j) * logProbs.Get(i, j)
 		}
 		losses.Set(i, 0, -sum)
 	}
-	return losses
+	return s.Reduction.Reduce(losses, weights)
 }
 
-// Gradient of observations
-func (c *CategoricalCrossEntropy) Gradient(observed *nn.Matrix, expected *nn.Matrix) *nn.Matrix {
+// Gradient of the reduced loss with respect to the logits, p - y for one-hot labels
+func (s *SoftmaxCrossEntropy) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	checkShapes(observed, expected)
+	grads := observed.Softmax()
+	total := expected.SumRows()
+	for i := 0; i < grads.Rows(); i++ {
+		for j := 0; j < grads.Cols(); j++ {
+			grads.Set(i, j, grads.Get(i, j)*total.Get(0, i)-expected.Get(i, j))
+		}
+	}
+	return s.Reduction.Scale(grads, weights)
+}
+
+// SparseCategoricalCrossEntropy categorical cross-entropy where expected is a (batch, 1)
+// column holding the index of each sample's class rather than a one-hot matrix
+type SparseCategoricalCrossEntropy struct {
+	Reduction nn.Reduction
+}
+
+// label the class index of sample i
+func label(expected *nn.Matrix, i int, classes int) int {
+	c := int(expected.Get(i, 0))
+	if c < 0 || c >= classes || float32(c) != expected.Get(i, 0) {
+		log.Fatalf("Label %v of sample %d is not a class index in [0, %d)", expected.Get(i, 0), i, classes)
+	}
+	return c
+}
+
+func checkSparse(observed *nn.Matrix, expected *nn.Matrix) {
+	if expected.Cols() != 1 || expected.Rows() != observed.Rows() {
+		log.Fatalf("Sparse labels must be a (%d, 1) column, got (%d, %d)", observed.Rows(), expected.Rows(), expected.Cols())
+	}
+}
+
+// Call will return the weighted losses for the batch, reduced according to s.Reduction
+func (s *SparseCategoricalCrossEntropy) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	checkSparse(observed, expected)
+	losses := nn.NewMatrix(observed.Rows(), 1)
+	for i := 0; i < observed.Rows(); i++ {
+		losses.Set(i, 0, -math.Log(clip(observed.Get(i, label(expected, i, observed.Cols())))))
+	}
+	return s.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to the observed probabilities
+func (s *SparseCategoricalCrossEntropy) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	checkSparse(observed, expected)
 	grads := nn.NewMatrixLike(observed)
-	//grads
+	for i := 0; i < observed.Rows(); i++ {
+		c := label(expected, i, observed.Cols())
+		grads.Set(i, c, -1/clip(observed.Get(i, c)))
+	}
+	return s.Reduction.Scale(grads, weights)
+}
+
+// FuseSoftmax computes the loss from the logits when the model ends in a softmax
+func (s *SparseCategoricalCrossEntropy) FuseSoftmax() nn.Loss {
+	return &SparseSoftmaxCrossEntropy{s.Reduction}
+}
+
+// SparseSoftmaxCrossEntropy SparseCategoricalCrossEntropy of the softmax of observed logits
+type SparseSoftmaxCrossEntropy struct {
+	Reduction nn.Reduction
+}
+
+// Call will return the weighted losses for the batch, reduced according to s.Reduction
+func (s *SparseSoftmaxCrossEntropy) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	checkSparse(observed, expected)
+	logProbs := logSoftmax(observed)
+	losses := nn.NewMatrix(observed.Rows(), 1)
+	for i := 0; i < observed.Rows(); i++ {
+		losses.Set(i, 0, -logProbs.Get(i, label(expected, i, observed.Cols())))
+	}
+	return s.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to the logits, p - onehot(y)
+func (s *SparseSoftmaxCrossEntropy) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	checkSparse(observed, expected)
+	grads := observed.Softmax()
+	for i := 0; i < observed.Rows(); i++ {
+		c := label(expected, i, observed.Cols())
+		grads.Set(i, c, grads.Get(i, c)-1)
+	}
+	return s.Reduction.Scale(grads, weights)
+}
+
+// KLDivergence sum(y * ln(y / p)), how far the observed distribution p is from the expected y
+type KLDivergence struct {
+	Reduction nn.Reduction
+}
+
+func (k *KLDivergence) losses(observed *nn.Matrix, expected *nn.Matrix) (*nn.Matrix, *nn.Matrix) {
+	checkShapes(observed, expected)
+	losses := nn.NewMatrix(observed.Rows(), 1)
+	grads := nn.NewMatrixLike(observed)
+	for i := 0; i < observed.Rows(); i++ {
+		var sum float32
+		for j := 0; j < observed.Cols(); j++ {
+			p, y := clip(observed.Get(i, j)), clip(expected.Get(i, j))
+			sum += y * math.Log(y/p)
+			grads.Set(i, j, -y/p)
+		}
+		losses.Set(i, 0, sum)
+	}
+	return losses, grads
+}
+
+// Call will return the weighted losses for the batch, reduced according to k.Reduction
+func (k *KLDivergence) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	losses, _ := k.losses(observed, expected)
+	return k.Reduction.Reduce(losses, weights)
+}
 
-	observed.Add(expected).Divn(1)
-	return grads
+// Gradient of the reduced loss with respect to the observed probabilities
+func (k *KLDivergence) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	_, grads := k.losses(observed, expected)
+	return k.Reduction.Scale(grads, weights)
 }
@@ -0,0 +1,179 @@
+package loss
+
+import (
+	math "github.com/chewxy/math32"
+	"log"
+	"nn-go/nn"
+)
+
+// MeanSquaredError mean((p - y)^2) over the outputs of each sample
+type MeanSquaredError struct {
+	Reduction nn.Reduction
+}
+
+func squaredError(p float32, y float32) (float32, float32) {
+	e := p - y
+	return e * e, 2 * e
+}
+
+// Call will return the weighted losses for the batch, reduced according to m.Reduction
+func (m *MeanSquaredError) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	losses, _ := meanOverValues(observed, expected, squaredError)
+	return m.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to observed
+func (m *MeanSquaredError) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	_, grads := meanOverValues(observed, expected, squaredError)
+	return m.Reduction.Scale(grads, weights)
+}
+
+// MeanAbsoluteError mean(|p - y|) over the outputs of each sample
+type MeanAbsoluteError struct {
+	Reduction nn.Reduction
+}
+
+func absoluteError(p float32, y float32) (float32, float32) {
+	e := p - y
+	switch {
+	case e > 0:
+		return e, 1
+	case e < 0:
+		return -e, -1
+	}
+	return 0, 0
+}
+
+// Call will return the weighted losses for the batch, reduced according to m.Reduction
+func (m *MeanAbsoluteError) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	losses, _ := meanOverValues(observed, expected, absoluteError)
+	return m.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to observed
+func (m *MeanAbsoluteError) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	_, grads := meanOverValues(observed, expected, absoluteError)
+	return m.Reduction.Scale(grads, weights)
+}
+
+// Huber quadratic for errors up to delta and linear beyond, so outliers have less influence than with MSE
+type Huber struct {
+	delta     float32
+	Reduction nn.Reduction
+}
+
+func NewHuberLoss(delta float32) *Huber {
+	if delta <= 0 {
+		log.Fatalf("Huber delta must be positive, got %f", delta)
+	}
+	return &Huber{delta: delta}
+}
+
+func (h *Huber) element(p float32, y float32) (float32, float32) {
+	e := p - y
+	if math.Abs(e) <= h.delta {
+		return 0.5 * e * e, e
+	}
+	return h.delta * (math.Abs(e) - 0.5*h.delta), h.delta * math.Copysign(1, e)
+}
+
+// Call will return the weighted losses for the batch, reduced according to h.Reduction
+func (h *Huber) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	losses, _ := meanOverValues(observed, expected, h.element)
+	return h.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to observed
+func (h *Huber) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	_, grads := meanOverValues(observed, expected, h.element)
+	return h.Reduction.Scale(grads, weights)
+}
+
+// LogCosh mean(ln(cosh(p - y))), roughly e^2/2 for small errors and |e| - ln(2) for large ones
+type LogCosh struct {
+	Reduction nn.Reduction
+}
+
+// logCosh rearranged as |e| + ln(1 + e^(-2|e|)) - ln(2) so cosh doesn't overflow
+func logCosh(p float32, y float32) (float32, float32) {
+	e := p - y
+	a := math.Abs(e)
+	return a + math.Log1p(math.Exp(-2*a)) - math.Ln2, math.Tanh(e)
+}
+
+// Call will return the weighted losses for the batch, reduced according to l.Reduction
+func (l *LogCosh) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	losses, _ := meanOverValues(observed, expected, logCosh)
+	return l.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to observed
+func (l *LogCosh) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	_, grads := meanOverValues(observed, expected, logCosh)
+	return l.Reduction.Scale(grads, weights)
+}
+
+// Poisson mean(p - y ln(p)) for observed rates p of expected counts y
+type Poisson struct {
+	Reduction nn.Reduction
+}
+
+func poisson(p float32, y float32) (float32, float32) {
+	p = math.Max(p, epsilon)
+	return p - y*math.Log(p), 1 - y/p
+}
+
+// Call will return the weighted losses for the batch, reduced according to l.Reduction
+func (l *Poisson) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	losses, _ := meanOverValues(observed, expected, poisson)
+	return l.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to observed
+func (l *Poisson) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	_, grads := meanOverValues(observed, expected, poisson)
+	return l.Reduction.Scale(grads, weights)
+}
+
+// CosineSimilarity the negative cosine similarity between each observed and expected row,
+// so that minimizing the loss makes them point the same way. Ranges from -1 to 1
+type CosineSimilarity struct {
+	Reduction nn.Reduction
+}
+
+func (c *CosineSimilarity) losses(observed *nn.Matrix, expected *nn.Matrix) (*nn.Matrix, *nn.Matrix) {
+	checkShapes(observed, expected)
+	rows, cols := observed.Shape()
+	losses := nn.NewMatrix(rows, 1)
+	grads := nn.NewMatrixLike(observed)
+	for i := 0; i < rows; i++ {
+		var dot, pp, yy float32
+		for j := 0; j < cols; j++ {
+			p, y := observed.Get(i, j), expected.Get(i, j)
+			dot += p * y
+			pp += p * p
+			yy += y * y
+		}
+		pNorm, yNorm := math.Max(math.Sqrt(pp), epsilon), math.Max(math.Sqrt(yy), epsilon)
+		cos := dot / (pNorm * yNorm)
+		losses.Set(i, 0, -cos)
+		// d(-cos)/dp = -(y / (|p||y|) - cos * p / |p|^2)
+		for j := 0; j < cols; j++ {
+			p, y := observed.Get(i, j), expected.Get(i, j)
+			grads.Set(i, j, -(y/(pNorm*yNorm) - cos*p/(pNorm*pNorm)))
+		}
+	}
+	return losses, grads
+}
+
+// Call will return the weighted losses for the batch, reduced according to c.Reduction
+func (c *CosineSimilarity) Call(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	losses, _ := c.losses(observed, expected)
+	return c.Reduction.Reduce(losses, weights)
+}
+
+// Gradient of the reduced loss with respect to observed
+func (c *CosineSimilarity) Gradient(observed *nn.Matrix, expected *nn.Matrix, weights *nn.Matrix) *nn.Matrix {
+	_, grads := c.losses(observed, expected)
+	return c.Reduction.Scale(grads, weights)
+}
@@ -69,6 +69,10 @@ func (m *Matrix) Fill(v float32) *Matrix {
 
 // Initialize a matrix with values by calling fn
 func (m *Matrix) Initialize(fn Initializer, layer Layer) *Matrix {
+	if mi, ok := fn.(MatrixInitializer); ok {
+		mi.InitMatrix(m, layer)
+		return m
+	}
 	for i := 0; i < m.rows; i++ {
 		for j := 0; j < m.cols; j++ {
 			m.v[i][j] = fn.Call(layer)
@@ -82,7 +86,7 @@ func (m *Matrix) Activate(fn Activator) *Matrix {
 	out := NewMatrix(m.rows, m.cols)
 	for i := 0; i < m.rows; i++ {
 		for j := 0; j < m.cols; j++ {
-			out.v[i][j] = fn(m.v[i][j])
+			out.v[i][j] = fn.Forward(m.v[i][j])
 		}
 	}
 	return out
@@ -92,12 +96,24 @@ func (m *Matrix) Activate(fn Activator) *Matrix {
 func (m *Matrix) ActivateInPlace(fn Activator) *Matrix {
 	for i := 0; i < m.rows; i++ {
 		for j := 0; j < m.cols; j++ {
-			m.v[i][j] = fn(m.v[i][j])
+			m.v[i][j] = fn.Forward(m.v[i][j])
 		}
 	}
 	return m
 }
 
+// Derivative the derivative of an activation at each value of the matrix. Returns a new matrix
+func (m *Matrix) Derivative(fn Activator) *Matrix {
+	out := NewMatrix(m.rows, m.cols)
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			x := m.v[i][j]
+			out.v[i][j] = fn.Derivative(x, fn.Forward(x))
+		}
+	}
+	return out
+}
+
 // check if 2 matrices have the same dimensions, crash if they do not
 func (m *Matrix) check(n *Matrix) {
 	if m.rows != n.rows || m.cols != n.cols {
@@ -186,8 +202,17 @@ func (m *Matrix) Divn(v float32) *Matrix {
 	return m
 }
 
-// Mult multiply all values in matrix m with those the same places in matrix n, in-place
+// Mult multiply all values in matrix m with those the same places in matrix n, in-place.
+// A row matrix n is multiplied into each row of m
 func (m *Matrix) Mult(n *Matrix) *Matrix {
+	if n.rows == 1 && n.cols == m.cols {
+		for i := 0; i < m.rows; i++ {
+			for j := 0; j < m.cols; j++ {
+				m.v[i][j] *= n.v[0][j]
+			}
+		}
+		return m
+	}
 	m.check(n)
 	for i := 0; i < m.rows; i++ {
 		for j := 0; j < m.cols; j++ {
@@ -456,3 +481,114 @@ func (m *Matrix) SumRows() *Matrix {
 	}
 	return out
 }
+
+// SliceCols copy the columns in the range [start, end) into a new matrix
+func (m *Matrix) SliceCols(start int, end int) *Matrix {
+	if start < 0 || end > m.cols || start >= end {
+		log.Fatalf("Invalid column range [%d, %d) for matrix with %d cols", start, end, m.cols)
+	}
+	out := NewMatrix(m.rows, end-start)
+	for i := 0; i < m.rows; i++ {
+		copy(out.v[i], m.v[i][start:end])
+	}
+	return out
+}
+
+// SetCols write the columns of n into this matrix starting at column start, in-place
+func (m *Matrix) SetCols(start int, n *Matrix) *Matrix {
+	if m.rows != n.rows || start < 0 || start+n.cols > m.cols {
+		log.Fatalf("Cannot set cols [%d, %d) of a (%d, %d) matrix from a (%d, %d) matrix",
+			start, start+n.cols, m.rows, m.cols, n.rows, n.cols)
+	}
+	for i := 0; i < m.rows; i++ {
+		copy(m.v[i][start:start+n.cols], n.v[i])
+	}
+	return m
+}
+
+// AddCols add n into the columns of this matrix starting at column start, in-place
+func (m *Matrix) AddCols(start int, n *Matrix) *Matrix {
+	if m.rows != n.rows || start < 0 || start+n.cols > m.cols {
+		log.Fatalf("Cannot add to cols [%d, %d) of a (%d, %d) matrix from a (%d, %d) matrix",
+			start, start+n.cols, m.rows, m.cols, n.rows, n.cols)
+	}
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < n.cols; j++ {
+			m.v[i][start+j] += n.v[i][j]
+		}
+	}
+	return m
+}
+
+// ConcatCols join matrices with the same number of rows side by side. Returns a new matrix
+func ConcatCols(ms ...*Matrix) *Matrix {
+	cols := 0
+	for _, n := range ms {
+		if n.rows != ms[0].rows {
+			log.Fatalf("Cannot concatenate matrices with different rows (%d != %d)", n.rows, ms[0].rows)
+		}
+		cols += n.cols
+	}
+	out := NewMatrix(ms[0].rows, cols)
+	start := 0
+	for _, n := range ms {
+		out.SetCols(start, n)
+		start += n.cols
+	}
+	return out
+}
+
+// SumCols the sum of each column. Returns a new array
+func (m *Matrix) SumCols() *Matrix {
+	out := NewMatrix(1, m.cols)
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			out.v[0][j] += m.v[i][j]
+		}
+	}
+	return out
+}
+
+// Reshape the values of the matrix, read row by row, into a new matrix of rows x cols
+func (m *Matrix) Reshape(rows int, cols int) *Matrix {
+	if rows*cols != m.rows*m.cols {
+		log.Fatalf("Cannot reshape a (%d, %d) matrix into (%d, %d)", m.rows, m.cols, rows, cols)
+	}
+	out := NewMatrix(rows, cols)
+	for k := 0; k < rows*cols; k++ {
+		out.v[k/cols][k%cols] = m.v[k/m.cols][k%m.cols]
+	}
+	return out
+}
+
+// SliceRows copy the rows in the range [start, end) into a new matrix
+func (m *Matrix) SliceRows(start int, end int) *Matrix {
+	if start < 0 || end > m.rows || start >= end {
+		log.Fatalf("Invalid row range [%d, %d) for matrix with %d rows", start, end, m.rows)
+	}
+	out := NewMatrix(end-start, m.cols)
+	for i := start; i < end; i++ {
+		copy(out.v[i-start], m.v[i])
+	}
+	return out
+}
+
+// ConcatRows stack matrices with the same number of columns on top of each other. Returns a new matrix
+func ConcatRows(ms ...*Matrix) *Matrix {
+	rows := 0
+	for _, n := range ms {
+		if n.cols != ms[0].cols {
+			log.Fatalf("Cannot stack matrices with different cols (%d != %d)", n.cols, ms[0].cols)
+		}
+		rows += n.rows
+	}
+	out := NewMatrix(rows, ms[0].cols)
+	start := 0
+	for _, n := range ms {
+		for i := 0; i < n.rows; i++ {
+			copy(out.v[start+i], n.v[i])
+		}
+		start += n.rows
+	}
+	return out
+}
@@ -0,0 +1,8 @@
+package nn
+
+// Metric scores predictions against the expected labels, e.g. accuracy. Unlike a Loss it is only
+// reported during training, not differentiated, so Call returns the score of the whole batch
+type Metric interface {
+	Name() string
+	Call(observed *Matrix, expected *Matrix) float32
+}
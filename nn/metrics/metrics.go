@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+)
+
+// CategoricalAccuracy the fraction of samples whose highest prediction is the class of their one-hot label
+type CategoricalAccuracy struct{}
+
+func (a CategoricalAccuracy) Name() string {
+	return "accuracy"
+}
+
+func (a CategoricalAccuracy) Call(observed *nn.Matrix, expected *nn.Matrix) float32 {
+	return observed.ArgMax().Eq(expected.ArgMax()).Mean()
+}
+
+// BinaryAccuracy the fraction of predictions on the same side of threshold as their 0 or 1 label
+type BinaryAccuracy struct {
+	threshold float32
+}
+
+func NewBinaryAccuracy(threshold float32) BinaryAccuracy {
+	return BinaryAccuracy{threshold}
+}
+
+func (a BinaryAccuracy) Name() string {
+	return "binary_accuracy"
+}
+
+func (a BinaryAccuracy) Call(observed *nn.Matrix, expected *nn.Matrix) float32 {
+	var correct float32
+	rows, cols := observed.Shape()
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			if (observed.Get(i, j) > a.threshold) == (expected.Get(i, j) > 0.5) {
+				correct++
+			}
+		}
+	}
+	return correct / float32(rows*cols)
+}
+
+// MeanAbsoluteError the mean of |observed - expected| over every value
+type MeanAbsoluteError struct{}
+
+func (m MeanAbsoluteError) Name() string {
+	return "mae"
+}
+
+func (m MeanAbsoluteError) Call(observed *nn.Matrix, expected *nn.Matrix) float32 {
+	var sum float32
+	rows, cols := observed.Shape()
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			sum += math.Abs(observed.Get(i, j) - expected.Get(i, j))
+		}
+	}
+	return sum / float32(rows*cols)
+}
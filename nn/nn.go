@@ -1,48 +1,138 @@
 package nn
 
 import (
-	"fmt"
 	"log"
 	"math/rand"
 	"time"
 )
 
 type Model struct {
-	inputs      int
+	inputs      Shape
+	shapes      []Shape
 	layers      []Layer
+	names       []string
 	initialized bool
 	loss        Loss
 	optimizer   Optimizer
+	fusedLoss   Loss
+	// stopTraining set by StopTraining to end Train early
+	stopTraining bool
 }
 
-func NewModel(inputs int, loss Loss, optimizer Optimizer) *Model {
+// NewModel create a model whose samples have the inputs shape
+func NewModel(inputs Shape, loss Loss, optimizer Optimizer) *Model {
 	var layers_ []Layer
 	return &Model{
 		inputs,
+		nil,
 		layers_,
+		nil,
 		false,
 		loss,
 		optimizer,
+		nil,
+		false,
 	}
 }
 
 func (m *Model) AddLayer(layer Layer) *Model {
+	return m.AddNamedLayer("", layer)
+}
+
+// AddNamedLayer add a layer that can be found by name, e.g. to give its params their own optimizer
+func (m *Model) AddNamedLayer(name string, layer Layer) *Model {
+	if name != "" && m.LayerIndex(name) >= 0 {
+		log.Fatalf("Model already has a layer named %s", name)
+	}
 	m.layers = append(m.layers, layer)
+	m.names = append(m.names, name)
 	return m
 }
 
-// Init initialise the model weights
+// LayerIndex the index of the layer named name, or -1 if there isn't one
+func (m *Model) LayerIndex(name string) int {
+	for i, n := range m.names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// LayerParams the trainable parameters of the layers at indices, negative indices count back from
+// the last layer
+func (m *Model) LayerParams(indices ...int) []*Param {
+	var params []*Param
+	for _, i := range indices {
+		if i < 0 {
+			i += len(m.layers)
+		}
+		if i < 0 || i >= len(m.layers) {
+			log.Fatalf("Layer index %d out of range for a model of %d layers", i, len(m.layers))
+		}
+		if p, ok := m.layers[i].(ParamLayer); ok {
+			params = append(params, p.Params()...)
+		}
+	}
+	return params
+}
+
+// NamedLayerParams the trainable parameters of the layers with names
+func (m *Model) NamedLayerParams(names ...string) []*Param {
+	var params []*Param
+	for _, name := range names {
+		i := m.LayerIndex(name)
+		if i < 0 || name == "" {
+			log.Fatalf("Model has no layer named %q", name)
+		}
+		params = append(params, m.LayerParams(i)...)
+	}
+	return params
+}
+
+// Init initialise the model weights, checking that the output shape of each layer
+// can be used as the input of the next
 func (m *Model) Init() {
 	if len(m.layers) == 0 {
 		log.Fatal("Model must have at least 1 layer")
 	}
-	inputs := m.inputs
-	for _, l := range m.layers {
-		inputs = l.Init(inputs)
+	if !m.inputs.Valid() {
+		log.Fatalf("Invalid model input shape %v", m.inputs)
+	}
+	shape := m.inputs.Copy()
+	m.shapes = []Shape{shape}
+	for i, l := range m.layers {
+		shape = l.Init(shape.Copy())
+		if !shape.Valid() {
+			log.Fatalf("Layer %d (%T) has invalid output shape %v for input shape %v", i, l, shape, m.shapes[i])
+		}
+		m.shapes = append(m.shapes, shape)
 	}
+	m.fuseSoftmax()
 	m.initialized = true
 }
 
+// fuseSoftmax when the model ends in a softmax and the loss has a fused version, train with the
+// fused loss on the softmax's input. It is numerically stable and its gradient is simply p - y
+func (m *Model) fuseSoftmax() {
+	m.fusedLoss = nil
+	fusable, ok := m.loss.(FusedSoftmaxLoss)
+	softmax, isSoftmax := m.layers[len(m.layers)-1].(SoftmaxLayer)
+	if ok && isSoftmax && softmax.IsSoftmax() && len(m.OutputShape()) == 1 {
+		m.fusedLoss = fusable.FuseSoftmax()
+	}
+}
+
+// Shapes the input shape of the model followed by the output shape of each layer
+func (m *Model) Shapes() []Shape {
+	return m.shapes
+}
+
+// OutputShape the shape of a sample output by the model
+func (m *Model) OutputShape() Shape {
+	return m.shapes[len(m.shapes)-1]
+}
+
 // Forward calculate the forward pass through the network and calculate the final output
 // return the activations of the input and each layer
 func (m *Model) Forward(inputs *Matrix) []*Matrix {
@@ -50,6 +140,9 @@ func (m *Model) Forward(inputs *Matrix) []*Matrix {
 	if !m.initialized {
 		log.Fatal("Must call Init() before Forward()")
 	}
+	if inputs.cols != m.inputs.Size() {
+		log.Fatalf("Model expects samples of shape %v (%d values), got %d values", m.inputs, m.inputs.Size(), inputs.cols)
+	}
 	activations := inputs.Copy()
 	layerActivations = append(layerActivations, activations)
 	for _, l := range m.layers {
@@ -61,18 +154,136 @@ func (m *Model) Forward(inputs *Matrix) []*Matrix {
 
 // Loss calculate the loss and gradients
 func (m *Model) Loss(predictions *Matrix, target *Matrix) *Matrix {
-	return m.loss.Call(predictions, target)
+	return m.loss.Call(predictions, target, nil)
 }
 
 // LossGrads loss gradients
 func (m *Model) LossGrads(predictions *Matrix, y *Matrix) *Matrix {
-	return m.loss.Gradient(predictions, y)
+	return m.loss.Gradient(predictions, y, nil)
+}
+
+// trainingLoss the weighted loss of the final activations and its gradient, along with how many layers
+// the gradient should be backpropagated from. A fused softmax loss skips the final softmax layer
+func (m *Model) trainingLoss(activations []*Matrix, y *Matrix, weights *Matrix) (*Matrix, *Matrix, int) {
+	if m.fusedLoss != nil {
+		logits := activations[len(activations)-2]
+		return m.fusedLoss.Call(logits, y, weights), m.fusedLoss.Gradient(logits, y, weights), len(m.layers) - 1
+	}
+	predictions := activations[len(activations)-1]
+	return m.loss.Call(predictions, y, weights), m.loss.Gradient(predictions, y, weights), len(m.layers)
 }
 
-// Backward calculate the backward pass and update the weights
+// Backward calculate the backward pass, adding the gradient of every parameter to its Grad.
+// Step then updates the weights
 func (m *Model) Backward(activations []*Matrix, grads *Matrix) {
-	for i := len(m.layers); i != 0; i++ {
-		grads = m.layers[i].Backward(activations[len(m.layers)-i], grads, m.optimizer)
+	m.backward(activations, grads, len(m.layers))
+}
+
+// backward through the first layers layers, activations[i] is the input of layer i. Nothing before
+// the first trainable layer needs a gradient, so a frozen prefix of the model is skipped entirely
+func (m *Model) backward(activations []*Matrix, grads *Matrix, layers int) {
+	first := 0
+	for first < layers && !trainable(m.layers[first]) {
+		first++
+	}
+	for i := layers - 1; i >= first; i-- {
+		grads = m.layers[i].Backward(activations[i], grads)
+	}
+}
+
+// trainable whether l has params that aren't frozen
+func trainable(l Layer) bool {
+	if t, ok := l.(TrainableLayer); ok {
+		return t.Trainable()
+	}
+	p, ok := l.(ParamLayer)
+	return ok && len(p.Params()) > 0
+}
+
+// Params the trainable parameters of every layer that isn't frozen
+func (m *Model) Params() []*Param {
+	var params []*Param
+	for _, l := range m.layers {
+		if p, ok := l.(ParamLayer); ok && trainable(l) {
+			params = append(params, p.Params()...)
+		}
+	}
+	return params
+}
+
+// Freeze the weights of layers [from, to), e.g. a pretrained base while a new head trains. Their
+// params are left out of Params so the optimizer doesn't touch them, and when they start the model
+// the backward pass stops before them
+func (m *Model) Freeze(from int, to int) {
+	m.setTrainable(from, to, false)
+}
+
+// Unfreeze the weights of layers [from, to)
+func (m *Model) Unfreeze(from int, to int) {
+	m.setTrainable(from, to, true)
+}
+
+func (m *Model) setTrainable(from int, to int, trainable bool) {
+	if from < 0 || to > len(m.layers) || from > to {
+		log.Fatalf("Invalid layer range [%d, %d) for a model of %d layers", from, to, len(m.layers))
+	}
+	for i := from; i < to; i++ {
+		if t, ok := m.layers[i].(TrainableLayer); ok {
+			t.SetTrainable(trainable)
+		} else if _, ok := m.layers[i].(ParamLayer); ok {
+			log.Fatalf("Layer %d (%T) cannot be frozen", i, m.layers[i])
+		}
+	}
+}
+
+// Step update the weights with the optimizer from the gradients accumulated by Backward, then clear them
+func (m *Model) Step() {
+	params := m.Params()
+	m.optimizer.Step(params)
+	for _, p := range params {
+		p.ZeroGrad()
+	}
+	for _, l := range m.layers {
+		if c, ok := l.(ConstrainedLayer); ok && trainable(l) {
+			c.Constrain()
+		}
+	}
+}
+
+// setPenaltyGradients whether the Backward of every RegularizedLayer includes the gradients of its
+// penalties
+func (m *Model) setPenaltyGradients(enabled bool) {
+	for _, l := range m.layers {
+		if r, ok := l.(RegularizedLayer); ok {
+			r.SetPenaltyGradients(enabled)
+		}
+	}
+}
+
+// Penalty the total penalty of the regularizers of every layer, given the activations of a batch
+// from Forward
+func (m *Model) Penalty(activations []*Matrix) float32 {
+	var penalty float32
+	for i, l := range m.layers {
+		if r, ok := l.(RegularizedLayer); ok {
+			penalty += r.Penalty(activations[i], activations[i+1])
+		}
+	}
+	return penalty
+}
+
+// Closure a function that computes the loss of the whole of data with the current weights and sets
+// the gradients of the params to it, for optimizers like L-BFGS that evaluate the loss several times
+// per step
+func (m *Model) Closure(data DataSet) func() float32 {
+	return func() float32 {
+		for _, p := range m.Params() {
+			p.ZeroGrad()
+		}
+		activations := m.Forward(data.Instances)
+		losses, grads, layers := m.trainingLoss(activations, data.Labels, data.Weights)
+		m.backward(activations, grads, layers)
+		return totalLoss(losses) + m.Penalty(activations)
 	}
 }
 
@@ -85,10 +296,16 @@ func (m *Model) Predict(inputs *Matrix) *Matrix {
 type DataSet struct {
 	Instances *Matrix
 	Labels    *Matrix
+	// Weights an optional (n, 1) column weighting each instance in the loss
+	Weights *Matrix
 }
 
-func (d *DataSet) getBatch(size int, idx int) (*Matrix, *Matrix) {
-	return d.Instances.Batch(size, idx), d.Labels.Batch(size, idx)
+func (d *DataSet) getBatch(size int, idx int) (*Matrix, *Matrix, *Matrix) {
+	var weights *Matrix
+	if d.Weights != nil {
+		weights = d.Weights.Batch(size, idx)
+	}
+	return d.Instances.Batch(size, idx), d.Labels.Batch(size, idx), weights
 }
 
 type TrainTestSet struct {
@@ -101,34 +318,121 @@ func (d *DataSet) shuffle() {
 		j := rand.Intn(i + 1)
 		d.Instances.v[i], d.Instances.v[j] = d.Instances.v[j], d.Instances.v[i]
 		d.Labels.v[i], d.Labels.v[j] = d.Labels.v[j], d.Labels.v[i]
+		if d.Weights != nil {
+			d.Weights.v[i], d.Weights.v[j] = d.Weights.v[j], d.Weights.v[i]
+		}
 	}
 }
 
 type TrainArgs struct {
-	data              *TrainTestSet
-	validation        *Matrix
-	epochs            int
-	batchSize         int
-	shuffleAfterEpoch bool
+	data                 *TrainTestSet
+	validation           *Matrix
+	epochs               int
+	batchSize            int
+	shuffleAfterEpoch    bool
+	classWeights         []float32
+	balancedClassWeights bool
+	scheduler            Scheduler
+	clipping             *GradientClipping
+	accumulationSteps    int
+	ema                  *EMA
+	swa                  *SWA
+	ewc                  *EWC
+	metrics              []Metric
+	callbacks            []Callback
 }
 
 func NewTrainArgs(tts *TrainTestSet, validation *Matrix, epochs int, batchSize int, shuffle bool) *TrainArgs {
 	return &TrainArgs{
-		tts,
-		validation,
-		epochs,
-		batchSize,
-		shuffle,
+		data:              tts,
+		validation:        validation,
+		epochs:            epochs,
+		batchSize:         batchSize,
+		shuffleAfterEpoch: shuffle,
+		accumulationSteps: 1,
+	}
+}
+
+// WithSampleWeights weight each training instance in the loss by an (n, 1) column of weights
+func (a *TrainArgs) WithSampleWeights(weights *Matrix) *TrainArgs {
+	checkWeights(weights, a.data.Train.Instances.rows)
+	a.data.Train.Weights = weights
+	return a
+}
+
+// WithClassWeights weight each training instance in the loss by the weight of its class
+func (a *TrainArgs) WithClassWeights(weights []float32) *TrainArgs {
+	a.classWeights = weights
+	a.balancedClassWeights = false
+	return a
+}
+
+// WithBalancedClassWeights weight each class inversely to how often it appears in the training
+// labels, see BalancedClassWeights
+func (a *TrainArgs) WithBalancedClassWeights() *TrainArgs {
+	a.classWeights = nil
+	a.balancedClassWeights = true
+	return a
+}
+
+// WithScheduler set the learning rate of the optimizer with scheduler as training progresses
+func (a *TrainArgs) WithScheduler(scheduler Scheduler) *TrainArgs {
+	a.scheduler = scheduler
+	return a
+}
+
+// WithGradientClipping clip the gradients of every batch before the optimizer updates the weights
+func (a *TrainArgs) WithGradientClipping(clipping GradientClipping) *TrainArgs {
+	clipping.check()
+	a.clipping = &clipping
+	return a
+}
+
+// WithAccumulationSteps sum the gradients of steps batches before each update of the weights, for an
+// effective batch size of steps * batchSize without the memory cost. The summed gradients are divided
+// by the number of batches, matching the gradient of a mean-reduced loss over the larger batch.
+// At the end of an epoch any remaining batches are used in a smaller update
+func (a *TrainArgs) WithAccumulationSteps(steps int) *TrainArgs {
+	if steps < 1 {
+		log.Fatalf("Accumulation steps must be at least 1, got %d", steps)
 	}
+	a.accumulationSteps = steps
+	return a
+}
+
+// WithEMA update ema with the weights after every weight update
+func (a *TrainArgs) WithEMA(ema *EMA) *TrainArgs {
+	a.ema = ema
+	return a
+}
+
+// WithSWA average the weights of the last epochs with swa, and finish training with the averaged weights.
+// Don't combine it with a callback that restores weights, such as EarlyStopping with restoreBest
+func (a *TrainArgs) WithSWA(swa *SWA) *TrainArgs {
+	a.swa = swa
+	return a
+}
+
+// WithMetrics report metrics on the training and test data after every epoch
+func (a *TrainArgs) WithMetrics(metrics ...Metric) *TrainArgs {
+	a.metrics = append(a.metrics, metrics...)
+	return a
+}
+
+// WithCallbacks call callbacks as training progresses, in order
+func (a *TrainArgs) WithCallbacks(callbacks ...Callback) *TrainArgs {
+	a.callbacks = append(a.callbacks, callbacks...)
+	return a
 }
 
-type TrainingResults struct {
-	testLosses  []float32
-	trainLosses []float32
+// WithEWC add the penalty of ewc for forgetting the tasks it has consolidated to the loss
+func (a *TrainArgs) WithEWC(ewc *EWC) *TrainArgs {
+	a.ewc = ewc
+	return a
 }
 
-// Train the model.
-func (m *Model) Train(args *TrainArgs) {
+// Train the model, returning the History of the losses, metrics and learning rate
+func (m *Model) Train(args *TrainArgs) History {
 	if args.data.Train.Instances.rows != args.data.Train.Labels.rows {
 		log.Fatalf("Number of training instances does match number of labels (%d != %d)",
 			args.data.Train.Instances.rows, args.data.Train.Labels.rows)
@@ -139,41 +443,153 @@ func (m *Model) Train(args *TrainArgs) {
 			args.batchSize, remainder, args.batchSize-remainder)
 	}
 	totalBatches := trainSamplesCount / args.batchSize
+	classWeights := args.classWeights
+	if args.balancedClassWeights {
+		classWeights = BalancedClassWeights(args.data.Train.Labels)
+	}
 
-	var testLosses []float32
-	var trainLosses []float32
-	results := TrainingResults{
-		testLosses:  testLosses,
-		trainLosses: trainLosses,
+	history := newHistory(args.metrics)
+	batchSteps := 0
+	accumulated := 0
+	if args.scheduler != nil {
+		args.scheduler.Step(m.optimizer, 0, history)
 	}
-	for i := 1; i <= args.epochs; i++ {
-		epochStart := time.Now().UnixMilli()
+	m.stopTraining = false
+	logs := Logs{"epochs": float32(args.epochs), "batches": float32(totalBatches)}
+	for _, c := range args.callbacks {
+		c.OnTrainBegin(m, logs)
+	}
+	for i := 1; i <= args.epochs && !m.stopTraining; i++ {
+		epochStart := time.Now()
 		epochLossTotal := float32(0)
-		for batchIdx := 0; batchIdx < totalBatches; batchIdx++ {
-			batchX, batchY := args.data.Train.getBatch(args.batchSize, batchIdx)
+		metricTotals := make([]float32, len(args.metrics))
+		for _, c := range args.callbacks {
+			c.OnEpochBegin(m, i-1, Logs{})
+		}
+		if m.stopTraining {
+			break
+		}
+		batches := 0
+		for batchIdx := 0; batchIdx < totalBatches && !m.stopTraining; batchIdx++ {
+			batchLogs := Logs{"size": float32(args.batchSize)}
+			for _, c := range args.callbacks {
+				c.OnBatchBegin(m, batchIdx, batchLogs)
+			}
+			batches++
+			batchX, batchY, batchWeights := args.data.Train.getBatch(args.batchSize, batchIdx)
+			if classWeights != nil {
+				batchWeights = ClassSampleWeights(batchY, classWeights, batchWeights)
+			}
 			layerActivations := m.Forward(batchX)
-			predictions := layerActivations[len(layerActivations)-1]
-			batchLoss := m.Loss(predictions, batchY).Mean()
+			losses, lossGrads, layers := m.trainingLoss(layerActivations, batchY, batchWeights)
+			batchLoss := totalLoss(losses) + m.Penalty(layerActivations)
+			if args.ewc != nil {
+				batchLoss += args.ewc.Penalty(m.Params())
+			}
 			epochLossTotal += batchLoss
-			fmt.Printf("Batch %d activations=%d loss=%.3f\n", batchIdx, len(layerActivations), batchLoss)
-			lossGrads := m.LossGrads(predictions, batchY)
-			m.Backward(layerActivations, lossGrads)
+			history.BatchLoss = append(history.BatchLoss, batchLoss)
+			batchLogs["loss"] = batchLoss
+			for k, metric := range args.metrics {
+				score := metric.Call(layerActivations[len(layerActivations)-1], batchY)
+				metricTotals[k] += score
+				batchLogs[metric.Name()] = score
+			}
+			m.backward(layerActivations, lossGrads, layers)
+			if args.ewc != nil {
+				args.ewc.AddGradients(m.Params())
+			}
+			accumulated++
+			if accumulated < args.accumulationSteps && batchIdx < totalBatches-1 {
+				m.batchEnd(args.callbacks, batchIdx, batchLogs)
+				continue
+			}
+			params := m.Params()
+			if accumulated > 1 {
+				for _, p := range params {
+					p.Grad.Divn(float32(accumulated))
+				}
+			}
+			accumulated = 0
+			gradientNorm := GlobalNorm(params)
+			history.GradientNorms = append(history.GradientNorms, gradientNorm)
+			batchLogs["grad_norm"] = gradientNorm
+			if args.clipping != nil {
+				args.clipping.Clip(params)
+			}
+			m.Step()
+			if args.ema != nil {
+				args.ema.Update(params)
+			}
+			batchSteps++
+			if args.scheduler != nil && args.scheduler.Interval() == PerBatch {
+				args.scheduler.Step(m.optimizer, batchSteps, history)
+			}
+			m.batchEnd(args.callbacks, batchIdx, batchLogs)
+		}
+		if accumulated > 0 {
+			// Stopped part way through accumulating, drop the unused gradients
+			for _, p := range m.Params() {
+				p.ZeroGrad()
+			}
+			accumulated = 0
+		}
+		epochLogs := Logs{"loss": epochLossTotal / float32(batches)}
+		history.TrainLoss = append(history.TrainLoss, epochLogs["loss"])
+		for k, metric := range args.metrics {
+			epochLogs[metric.Name()] = metricTotals[k] / float32(batches)
+			history.Metrics[metric.Name()] = append(history.Metrics[metric.Name()], epochLogs[metric.Name()])
 		}
-		batchLossMean := epochLossTotal / float32(totalBatches)
-		results.testLosses = append(results.testLosses, batchLossMean)
 
-		// Evaluate performance, put it in array
+		// Evaluate performance on the test set
 		testX, testY := args.data.Test.Instances, args.data.Test.Labels
 		predictions := m.Predict(testX)
-
-		testLoss := m.Loss(predictions, testY).Mean()
-		testLosses = append(testLosses, testLoss)
+		testLoss := totalLoss(m.loss.Call(predictions, testY, args.data.Test.Weights))
+		history.ValidationLoss = append(history.ValidationLoss, testLoss)
+		epochLogs[validationPrefix+"loss"] = testLoss
+		for _, metric := range args.metrics {
+			name := validationPrefix + metric.Name()
+			epochLogs[name] = metric.Call(predictions, testY)
+			history.Metrics[name] = append(history.Metrics[name], epochLogs[name])
+		}
+		history.Lr = append(history.Lr, m.optimizer.Lr())
+		epochLogs["lr"] = m.optimizer.Lr()
 		// Shuffle if we want
 		if args.shuffleAfterEpoch {
 			args.data.Train.shuffle()
 		}
-		m.optimizer.Update(i, results)
-		epochEnd := time.Now().UnixMilli()
-		log.Printf("Epoch %d (%dms)", i, epochEnd-epochStart)
+		if args.swa != nil && i > args.epochs-args.swa.epochs {
+			args.swa.update(m.Params())
+		}
+		if args.scheduler != nil && args.scheduler.Interval() == PerEpoch {
+			args.scheduler.Step(m.optimizer, i, history)
+		}
+		duration := time.Since(epochStart)
+		history.EpochDuration = append(history.EpochDuration, duration)
+		epochLogs["duration"] = float32(duration.Seconds())
+		for _, c := range args.callbacks {
+			c.OnEpochEnd(m, i-1, epochLogs)
+		}
+		logs = epochLogs
 	}
+	if args.swa != nil && args.swa.count > 0 {
+		args.swa.average.copyTo(m.Params())
+		m.RefreshStatistics(args.data.Train.Instances, args.batchSize)
+	}
+	for _, c := range args.callbacks {
+		c.OnTrainEnd(m, logs)
+	}
+	return history
+}
+
+// batchEnd tell callbacks a batch has finished
+func (m *Model) batchEnd(callbacks []Callback, batch int, logs Logs) {
+	for _, c := range callbacks {
+		c.OnBatchEnd(m, batch, logs)
+	}
+}
+
+// StopTraining ask Train to stop after the current batch. The epoch is still evaluated and the
+// callbacks told it has ended
+func (m *Model) StopTraining() {
+	m.stopTraining = true
 }
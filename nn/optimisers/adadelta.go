@@ -0,0 +1,45 @@
+package optimisers
+
+import (
+	math "github.com/chewxy/math32"
+	"log"
+	"nn-go/nn"
+)
+
+// Adadelta scales each gradient by the ratio of the running root mean squares of recent updates
+// and recent gradients, so the step has the units of the parameter and needs no tuned learning rate.
+// lr only scales the final update
+type Adadelta struct {
+	learningRate
+	rho     float32
+	epsilon float32
+	state   map[*nn.Param]*moments
+}
+
+// NewAdadeltaOptimizer create an Adadelta optimizer, the paper uses lr 1, rho 0.95 and epsilon 1e-6
+func NewAdadeltaOptimizer(lr float32, rho float32, epsilon float32) *Adadelta {
+	if rho < 0 || rho >= 1 {
+		log.Fatalf("Adadelta rho must be in [0, 1), got %f", rho)
+	}
+	return &Adadelta{
+		learningRate: learningRate{lr},
+		rho:          rho,
+		epsilon:      epsilon,
+		state:        map[*nn.Param]*moments{},
+	}
+}
+
+func (a *Adadelta) Step(params []*nn.Param) {
+	for _, p := range params {
+		// v averages the squared gradients and m the squared updates
+		s := momentsOf(a.state, p)
+		each(p.Value, func(i int, j int) {
+			g := p.Grad.Get(i, j)
+			v := a.rho*s.v.Get(i, j) + (1-a.rho)*g*g
+			s.v.Set(i, j, v)
+			delta := math.Sqrt(s.m.Get(i, j)+a.epsilon) / math.Sqrt(v+a.epsilon) * g
+			s.m.Set(i, j, a.rho*s.m.Get(i, j)+(1-a.rho)*delta*delta)
+			p.Value.Set(i, j, p.Value.Get(i, j)-a.lr*delta)
+		})
+	}
+}
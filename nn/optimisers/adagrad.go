@@ -0,0 +1,35 @@
+package optimisers
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+)
+
+// Adagrad divides each gradient by the root of the sum of all its squared gradients so far,
+// so values with large or frequent gradients take smaller steps. Step sizes only ever shrink
+type Adagrad struct {
+	learningRate
+	epsilon float32
+	state   map[*nn.Param]*moments
+}
+
+// NewAdagradOptimizer create an Adagrad optimizer, the usual defaults are lr 0.01 and epsilon 1e-10
+func NewAdagradOptimizer(lr float32, epsilon float32) *Adagrad {
+	return &Adagrad{
+		learningRate: learningRate{lr},
+		epsilon:      epsilon,
+		state:        map[*nn.Param]*moments{},
+	}
+}
+
+func (a *Adagrad) Step(params []*nn.Param) {
+	for _, p := range params {
+		s := momentsOf(a.state, p)
+		each(p.Value, func(i int, j int) {
+			g := p.Grad.Get(i, j)
+			sum := s.v.Get(i, j) + g*g
+			s.v.Set(i, j, sum)
+			p.Value.Set(i, j, p.Value.Get(i, j)-a.lr*g/(math.Sqrt(sum)+a.epsilon))
+		})
+	}
+}
@@ -5,29 +5,50 @@ import (
 	"nn-go/nn"
 )
 
+// Adam adaptive moment estimation. Each parameter is moved by its bias-corrected first moment
+// over the square root of its bias-corrected second moment, so every value gets its own step size.
+// With amsgrad the largest second moment seen so far is used, so step sizes never grow
 type Adam struct {
-	alpha   float32
+	learningRate
 	beta1   float32
 	beta2   float32
 	epsilon float32
+	amsgrad bool
+	state   map[*nn.Param]*moments
 }
 
-func NewAdamOptimizer() *Adam {
+// NewAdamOptimizer create an Adam optimizer, the paper's defaults are
+// lr 0.001, beta1 0.9, beta2 0.999 and epsilon 1e-8
+func NewAdamOptimizer(lr float32, beta1 float32, beta2 float32, epsilon float32, amsgrad bool) *Adam {
+	checkBetas("Adam", beta1, beta2)
 	return &Adam{
-		alpha:   0.001,
-		beta1:   0.9,
-		beta2:   0.999,
-		epsilon: 1. / 1e8,
+		learningRate: learningRate{lr},
+		beta1:        beta1,
+		beta2:        beta2,
+		epsilon:      epsilon,
+		amsgrad:      amsgrad,
+		state:        map[*nn.Param]*moments{},
 	}
 }
-func (a *Adam) Call(layer nn.Layer) float32 {
-	return 0
-}
-
-func (a *Adam) Update(epoch int, _ nn.TrainingResults) {
-	a.alpha = a.alpha / math.Sqrt(float32(epoch))
-}
 
-func (a *Adam) Lr() float32 {
-	return a.alpha
+func (a *Adam) Step(params []*nn.Param) {
+	for _, p := range params {
+		s := momentsOf(a.state, p)
+		s.step++
+		correction1 := 1 - math.Pow(a.beta1, float32(s.step))
+		correction2 := 1 - math.Pow(a.beta2, float32(s.step))
+		each(p.Value, func(i int, j int) {
+			g := p.Grad.Get(i, j)
+			m := a.beta1*s.m.Get(i, j) + (1-a.beta1)*g
+			v := a.beta2*s.v.Get(i, j) + (1-a.beta2)*g*g
+			s.m.Set(i, j, m)
+			s.v.Set(i, j, v)
+			if a.amsgrad {
+				v = math.Max(v, s.vMax.Get(i, j))
+				s.vMax.Set(i, j, v)
+			}
+			step := a.lr * (m / correction1) / (math.Sqrt(v/correction2) + a.epsilon)
+			p.Value.Set(i, j, p.Value.Get(i, j)-step)
+		})
+	}
 }
@@ -0,0 +1,46 @@
+package optimisers
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+)
+
+// Adamax Adam with the second moment replaced by an exponentially weighted infinity norm,
+// u = max(beta2 * u, |g|), which needs no bias correction
+type Adamax struct {
+	learningRate
+	beta1   float32
+	beta2   float32
+	epsilon float32
+	state   map[*nn.Param]*moments
+}
+
+// NewAdamaxOptimizer create an Adamax optimizer, the paper uses lr 0.002, beta1 0.9 and beta2 0.999.
+// epsilon, usually 1e-8, is added to |g| so values that have never had a gradient don't divide by 0
+func NewAdamaxOptimizer(lr float32, beta1 float32, beta2 float32, epsilon float32) *Adamax {
+	checkBetas("Adamax", beta1, beta2)
+	return &Adamax{
+		learningRate: learningRate{lr},
+		beta1:        beta1,
+		beta2:        beta2,
+		epsilon:      epsilon,
+		state:        map[*nn.Param]*moments{},
+	}
+}
+
+func (a *Adamax) Step(params []*nn.Param) {
+	for _, p := range params {
+		// v holds the infinity norm u
+		s := momentsOf(a.state, p)
+		s.step++
+		stepSize := a.lr / (1 - math.Pow(a.beta1, float32(s.step)))
+		each(p.Value, func(i int, j int) {
+			g := p.Grad.Get(i, j)
+			m := a.beta1*s.m.Get(i, j) + (1-a.beta1)*g
+			u := math.Max(a.beta2*s.v.Get(i, j), math.Abs(g)+a.epsilon)
+			s.m.Set(i, j, m)
+			s.v.Set(i, j, u)
+			p.Value.Set(i, j, p.Value.Get(i, j)-stepSize*m/u)
+		})
+	}
+}
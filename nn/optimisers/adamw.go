@@ -0,0 +1,36 @@
+package optimisers
+
+import (
+	"log"
+	"nn-go/nn"
+)
+
+// AdamW Adam with decoupled weight decay. Rather than adding weightDecay * value to the
+// gradient, where Adam's normalization would rescale it, every value is shrunk by
+// lr * weightDecay * value before the Adam update
+type AdamW struct {
+	*Adam
+	weightDecay float32
+}
+
+// NewAdamWOptimizer create an AdamW optimizer, the usual defaults are lr 0.001, beta1 0.9,
+// beta2 0.999, epsilon 1e-8 and weight decay 0.01
+func NewAdamWOptimizer(
+	lr float32,
+	beta1 float32,
+	beta2 float32,
+	epsilon float32,
+	weightDecay float32,
+	amsgrad bool) *AdamW {
+	if weightDecay < 0 {
+		log.Fatalf("AdamW weight decay cannot be negative, got %f", weightDecay)
+	}
+	return &AdamW{NewAdamOptimizer(lr, beta1, beta2, epsilon, amsgrad), weightDecay}
+}
+
+func (a *AdamW) Step(params []*nn.Param) {
+	for _, p := range params {
+		p.Value.Multn(1 - a.lr*a.weightDecay)
+	}
+	a.Adam.Step(params)
+}
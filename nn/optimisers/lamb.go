@@ -0,0 +1,64 @@
+package optimisers
+
+import (
+	math "github.com/chewxy/math32"
+	"log"
+	"nn-go/nn"
+)
+
+// LAMB layer-wise adaptive moments for large batch training. Each parameter's Adam update plus
+// weight decay, r, is rescaled by the trust ratio ||value|| / ||r|| so that every parameter moves
+// by the same fraction of its size, whatever the scale of its gradients
+type LAMB struct {
+	learningRate
+	beta1       float32
+	beta2       float32
+	epsilon     float32
+	weightDecay float32
+	state       map[*nn.Param]*moments
+}
+
+// NewLAMBOptimizer create a LAMB optimizer, the usual defaults are lr 0.001, beta1 0.9,
+// beta2 0.999, epsilon 1e-6 and weight decay 0.01
+func NewLAMBOptimizer(lr float32, beta1 float32, beta2 float32, epsilon float32, weightDecay float32) *LAMB {
+	checkBetas("LAMB", beta1, beta2)
+	if weightDecay < 0 {
+		log.Fatalf("LAMB weight decay cannot be negative, got %f", weightDecay)
+	}
+	return &LAMB{
+		learningRate: learningRate{lr},
+		beta1:        beta1,
+		beta2:        beta2,
+		epsilon:      epsilon,
+		weightDecay:  weightDecay,
+		state:        map[*nn.Param]*moments{},
+	}
+}
+
+func (l *LAMB) Step(params []*nn.Param) {
+	for _, p := range params {
+		s := momentsOf(l.state, p)
+		s.step++
+		correction1 := 1 - math.Pow(l.beta1, float32(s.step))
+		correction2 := 1 - math.Pow(l.beta2, float32(s.step))
+		update := nn.NewMatrixLike(p.Value)
+		var valueNorm, updateNorm float32
+		each(p.Value, func(i int, j int) {
+			g, value := p.Grad.Get(i, j), p.Value.Get(i, j)
+			m := l.beta1*s.m.Get(i, j) + (1-l.beta1)*g
+			v := l.beta2*s.v.Get(i, j) + (1-l.beta2)*g*g
+			s.m.Set(i, j, m)
+			s.v.Set(i, j, v)
+			r := (m/correction1)/(math.Sqrt(v/correction2)+l.epsilon) + l.weightDecay*value
+			update.Set(i, j, r)
+			valueNorm += value * value
+			updateNorm += r * r
+		})
+		// Fall back to a ratio of 1 for values or updates that are all 0, e.g. zero initialised biases
+		trust := float32(1)
+		if valueNorm > 0 && updateNorm > 0 {
+			trust = math.Sqrt(valueNorm) / math.Sqrt(updateNorm)
+		}
+		p.Value.Sub(update.Multn(l.lr * trust))
+	}
+}
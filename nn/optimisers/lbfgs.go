@@ -0,0 +1,284 @@
+package optimisers
+
+import (
+	math "github.com/chewxy/math32"
+	"log"
+	"nn-go/nn"
+)
+
+// LBFGS limited memory BFGS, a quasi-Newton method that approximates the inverse Hessian from the
+// last history changes in the weights and gradients. Each iteration searches along its direction
+// for a step satisfying the strong Wolfe conditions. It needs the loss of the same full batch many
+// times per step, so rather than implementing nn.Optimizer it minimizes a closure, see
+// nn.Model.Closure. Works best on small, smooth, deterministic problems
+type LBFGS struct {
+	learningRate
+	history   int
+	maxIter   int
+	tolerance float32
+	// s the changes in the weights and y the changes in the gradient, oldest first
+	s, y [][]float32
+	// iterations how many iterations have been taken over every call to Minimize
+	iterations int
+}
+
+const (
+	// wolfeC1 sufficient decrease of the loss
+	wolfeC1 = 1e-4
+	// wolfeC2 sufficient decrease of the directional derivative
+	wolfeC2 = 0.9
+	// maxLineSearch evaluations of the closure in a line search
+	maxLineSearch = 25
+	// toleranceChange stops when the weights or loss change by less
+	toleranceChange = 1e-9
+)
+
+// NewLBFGSOptimizer create an L-BFGS optimizer keeping history updates, taking at most maxIter
+// iterations per call to Minimize and stopping early once every gradient is within tolerance of 0.
+// lr scales the initial step of each line search, usually 1
+func NewLBFGSOptimizer(lr float32, history int, maxIter int, tolerance float32) *LBFGS {
+	if lr <= 0 {
+		log.Fatalf("L-BFGS learning rate must be positive, got %f", lr)
+	}
+	if history < 1 {
+		log.Fatalf("L-BFGS history size must be at least 1, got %d", history)
+	}
+	if maxIter < 1 {
+		log.Fatalf("L-BFGS must take at least 1 iteration, got %d", maxIter)
+	}
+	return &LBFGS{learningRate: learningRate{lr}, history: history, maxIter: maxIter, tolerance: tolerance}
+}
+
+func dot(a []float32, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func maxAbs(a []float32) float32 {
+	var m float32
+	for _, x := range a {
+		m = math.Max(m, math.Abs(x))
+	}
+	return m
+}
+
+// direction the two-loop recursion, the approximate inverse Hessian times -g
+func (l *LBFGS) direction(g []float32) []float32 {
+	d := make([]float32, len(g))
+	for i := range g {
+		d[i] = -g[i]
+	}
+	alphas := make([]float32, len(l.s))
+	for k := len(l.s) - 1; k >= 0; k-- {
+		alphas[k] = dot(l.s[k], d) / dot(l.y[k], l.s[k])
+		for i := range d {
+			d[i] -= alphas[k] * l.y[k][i]
+		}
+	}
+	if n := len(l.s); n > 0 {
+		gamma := dot(l.s[n-1], l.y[n-1]) / dot(l.y[n-1], l.y[n-1])
+		for i := range d {
+			d[i] *= gamma
+		}
+	}
+	for k := range l.s {
+		beta := dot(l.y[k], d) / dot(l.y[k], l.s[k])
+		for i := range d {
+			d[i] += l.s[k][i] * (alphas[k] - beta)
+		}
+	}
+	return d
+}
+
+// remember the change in weights s and gradient y, skipping it when the curvature ys is not positive
+func (l *LBFGS) remember(s []float32, y []float32) {
+	if dot(y, s) <= 1e-10 {
+		return
+	}
+	if len(l.s) == l.history {
+		l.s, l.y = l.s[1:], l.y[1:]
+	}
+	l.s, l.y = append(l.s, s), append(l.y, y)
+}
+
+// Minimize the loss returned by closure, which must also set the gradients of params, returning the
+// final loss. The history is kept between calls
+func (l *LBFGS) Minimize(params []*nn.Param, closure func() float32) float32 {
+	loss := closure()
+	g := nn.FlattenGrads(params)
+	for iter := 0; iter < l.maxIter && maxAbs(g) > l.tolerance; iter++ {
+		d := l.direction(g)
+		gtd := dot(g, d)
+		if gtd > -toleranceChange {
+			break
+		}
+		t := l.lr
+		if l.iterations == 0 {
+			// Without any curvature yet the direction is -g, keep the first step small
+			var sum float32
+			for _, x := range g {
+				sum += math.Abs(x)
+			}
+			t = math.Min(1, 1/sum) * l.lr
+		}
+		l.iterations++
+		x := nn.FlattenValues(params)
+		search := &lineSearch{params: params, closure: closure, x: x, d: d}
+		newLoss, newG, t := search.strongWolfe(t, loss, g, gtd)
+		s, y := make([]float32, len(d)), make([]float32, len(g))
+		for i := range d {
+			s[i] = t * d[i]
+			y[i] = newG[i] - g[i]
+		}
+		l.remember(s, y)
+		change := loss - newLoss
+		loss, g = newLoss, newG
+		if maxAbs(s) <= toleranceChange || math.Abs(change) < toleranceChange {
+			break
+		}
+	}
+	return loss
+}
+
+// lineSearch evaluates the loss along direction d from the weights x
+type lineSearch struct {
+	params  []*nn.Param
+	closure func() float32
+	x, d    []float32
+	evals   int
+}
+
+// evaluate the loss, gradient and directional derivative at step t
+func (s *lineSearch) evaluate(t float32) (float32, []float32, float32) {
+	s.set(t)
+	s.evals++
+	loss := s.closure()
+	g := nn.FlattenGrads(s.params)
+	return loss, g, dot(g, s.d)
+}
+
+// set the weights to x + t*d
+func (s *lineSearch) set(t float32) {
+	moved := make([]float32, len(s.x))
+	for i := range s.x {
+		moved[i] = s.x[i] + t*s.d[i]
+	}
+	nn.SetValues(s.params, moved)
+}
+
+// cubicMinimum the minimum in [lo, hi] of the cubic interpolating the losses f and directional
+// derivatives g at steps x1 and x2, or the middle when there isn't one
+func cubicMinimum(x1, f1, g1, x2, f2, g2, lo, hi float32) float32 {
+	d1 := g1 + g2 - 3*(f1-f2)/(x1-x2)
+	d2sq := d1*d1 - g1*g2
+	if d2sq < 0 {
+		return (lo + hi) / 2
+	}
+	d2 := math.Sqrt(d2sq)
+	var t float32
+	if x1 <= x2 {
+		t = x2 - (x2-x1)*((g2+d2-d1)/(g2-g1+2*d2))
+	} else {
+		t = x1 - (x1-x2)*((g1+d2-d1)/(g1-g2+2*d2))
+	}
+	if math.IsNaN(t) || math.IsInf(t, 0) {
+		return (lo + hi) / 2
+	}
+	return math.Min(math.Max(t, lo), hi)
+}
+
+// point a step along the search direction with its loss, gradient and directional derivative
+type point struct {
+	t, f, gtd float32
+	g         []float32
+}
+
+// strongWolfe find a step t from t0 with sufficient decrease of the loss and of the magnitude of the
+// directional derivative, first bracketing one by extrapolation then zooming in. Returns the loss,
+// gradient and step found, leaving the weights at that step
+func (s *lineSearch) strongWolfe(t0 float32, f0 float32, g0 []float32, gtd0 float32) (float32, []float32, float32) {
+	start := point{0, f0, gtd0, g0}
+	prev := start
+	f, g, gtd := s.evaluate(t0)
+	current := point{t0, f, gtd, g}
+	var bracket []point
+	for {
+		if current.f > f0+wolfeC1*current.t*gtd0 || (prev.t > 0 && current.f >= prev.f) {
+			bracket = []point{prev, current}
+			break
+		}
+		if math.Abs(current.gtd) <= -wolfeC2*gtd0 {
+			s.set(current.t)
+			return current.f, current.g, current.t
+		}
+		if current.gtd >= 0 {
+			bracket = []point{prev, current}
+			break
+		}
+		if s.evals >= maxLineSearch {
+			bracket = []point{start, current}
+			break
+		}
+		// Extrapolate to a longer step
+		lo, hi := current.t+0.01*(current.t-prev.t), current.t*10
+		t := cubicMinimum(prev.t, prev.f, prev.gtd, current.t, current.f, current.gtd, lo, hi)
+		prev = current
+		f, g, gtd := s.evaluate(t)
+		current = point{t, f, gtd, g}
+	}
+
+	// Zoom in on the bracket, low is the end with the lower loss
+	low, high := 0, 1
+	if bracket[1].f < bracket[0].f {
+		low, high = 1, 0
+	}
+	insufficientProgress := false
+	for s.evals < maxLineSearch {
+		if math.Abs(bracket[1].t-bracket[0].t)*maxAbs(s.d) < toleranceChange {
+			break
+		}
+		lo, hi := math.Min(bracket[0].t, bracket[1].t), math.Max(bracket[0].t, bracket[1].t)
+		t := cubicMinimum(bracket[0].t, bracket[0].f, bracket[0].gtd, bracket[1].t, bracket[1].f, bracket[1].gtd, lo, hi)
+		// Keep away from the ends of the bracket so it keeps shrinking
+		eps := 0.1 * (hi - lo)
+		if math.Min(hi-t, t-lo) < eps {
+			if insufficientProgress || t >= hi || t <= lo {
+				if math.Abs(t-hi) < math.Abs(t-lo) {
+					t = hi - eps
+				} else {
+					t = lo + eps
+				}
+				insufficientProgress = false
+			} else {
+				insufficientProgress = true
+			}
+		} else {
+			insufficientProgress = false
+		}
+		f, g, gtd := s.evaluate(t)
+		next := point{t, f, gtd, g}
+		if next.f > f0+wolfeC1*t*gtd0 || next.f >= bracket[low].f {
+			bracket[high] = next
+		} else {
+			if math.Abs(next.gtd) <= -wolfeC2*gtd0 {
+				s.set(next.t)
+				return next.f, next.g, next.t
+			}
+			if next.gtd*(bracket[high].t-bracket[low].t) >= 0 {
+				bracket[high] = bracket[low]
+			}
+			bracket[low] = next
+		}
+		if bracket[1].f < bracket[0].f {
+			low, high = 1, 0
+		} else {
+			low, high = 0, 1
+		}
+	}
+	best := bracket[low]
+	s.set(best.t)
+	return best.f, best.g, best.t
+}
@@ -0,0 +1,49 @@
+package optimisers
+
+import (
+	"log"
+	"nn-go/nn"
+)
+
+// Lookahead wraps another optimizer, the fast weights take k steps of it, then the slow weights move
+// alpha of the way towards them and the fast weights restart from the slow ones. It makes the
+// wrapped optimizer less sensitive to its learning rate and reduces the variance of its updates
+type Lookahead struct {
+	nn.Optimizer
+	k     int
+	alpha float32
+	steps int
+	slow  map[*nn.Param]*nn.Matrix
+}
+
+// NewLookahead wrap optimizer with k fast steps per slow step, the paper uses k 5 and alpha 0.5
+func NewLookahead(optimizer nn.Optimizer, k int, alpha float32) *Lookahead {
+	if k < 1 {
+		log.Fatalf("Lookahead must take at least 1 fast step, got %d", k)
+	}
+	if alpha <= 0 || alpha > 1 {
+		log.Fatalf("Lookahead alpha must be in (0, 1], got %f", alpha)
+	}
+	return &Lookahead{Optimizer: optimizer, k: k, alpha: alpha, slow: map[*nn.Param]*nn.Matrix{}}
+}
+
+func (l *Lookahead) Step(params []*nn.Param) {
+	for _, p := range params {
+		if _, ok := l.slow[p]; !ok {
+			l.slow[p] = p.Value.Copy()
+		}
+	}
+	l.Optimizer.Step(params)
+	l.steps++
+	if l.steps%l.k != 0 {
+		return
+	}
+	for _, p := range params {
+		slow := l.slow[p]
+		each(slow, func(i int, j int) {
+			s := slow.Get(i, j) + l.alpha*(p.Value.Get(i, j)-slow.Get(i, j))
+			slow.Set(i, j, s)
+			p.Value.Set(i, j, s)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package optimisers
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+)
+
+// Nadam Adam with Nesterov momentum: the step uses the bias-corrected first moment of the
+// next step, beta1 * m + (1 - beta1) * g, looking ahead along the momentum. This is the
+// form in Dozat's paper with a constant beta1, without the momentum schedule
+type Nadam struct {
+	learningRate
+	beta1   float32
+	beta2   float32
+	epsilon float32
+	state   map[*nn.Param]*moments
+}
+
+// NewNadamOptimizer create a Nadam optimizer, the usual defaults are lr 0.002, beta1 0.9,
+// beta2 0.999 and epsilon 1e-8
+func NewNadamOptimizer(lr float32, beta1 float32, beta2 float32, epsilon float32) *Nadam {
+	checkBetas("Nadam", beta1, beta2)
+	return &Nadam{
+		learningRate: learningRate{lr},
+		beta1:        beta1,
+		beta2:        beta2,
+		epsilon:      epsilon,
+		state:        map[*nn.Param]*moments{},
+	}
+}
+
+func (n *Nadam) Step(params []*nn.Param) {
+	for _, p := range params {
+		s := momentsOf(n.state, p)
+		s.step++
+		correction1 := 1 - math.Pow(n.beta1, float32(s.step))
+		nextCorrection1 := 1 - math.Pow(n.beta1, float32(s.step+1))
+		correction2 := 1 - math.Pow(n.beta2, float32(s.step))
+		each(p.Value, func(i int, j int) {
+			g := p.Grad.Get(i, j)
+			m := n.beta1*s.m.Get(i, j) + (1-n.beta1)*g
+			v := n.beta2*s.v.Get(i, j) + (1-n.beta2)*g*g
+			s.m.Set(i, j, m)
+			s.v.Set(i, j, v)
+			lookahead := n.beta1*m/nextCorrection1 + (1-n.beta1)*g/correction1
+			p.Value.Set(i, j, p.Value.Get(i, j)-n.lr*lookahead/(math.Sqrt(v/correction2)+n.epsilon))
+		})
+	}
+}
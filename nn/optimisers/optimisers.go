@@ -0,0 +1,55 @@
+package optimisers
+
+import (
+	"log"
+	"nn-go/nn"
+)
+
+// learningRate the learning rate of an optimizer, SetLr lets a schedule change it between steps
+type learningRate struct {
+	lr float32
+}
+
+func (l *learningRate) Lr() float32 {
+	return l.lr
+}
+
+func (l *learningRate) SetLr(lr float32) {
+	l.lr = lr
+}
+
+// each call fn with the row and column of every value in m
+func each(m *nn.Matrix, fn func(i int, j int)) {
+	rows, cols := m.Shape()
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			fn(i, j)
+		}
+	}
+}
+
+// moments the running averages an optimizer keeps for a parameter, usually of the gradient
+// and the squared gradient, and how many steps the parameter has taken
+type moments struct {
+	step int
+	m    *nn.Matrix
+	v    *nn.Matrix
+	vMax *nn.Matrix
+}
+
+// momentsOf the moments of p, starting at zero the first time p is seen
+func momentsOf(state map[*nn.Param]*moments, p *nn.Param) *moments {
+	s, ok := state[p]
+	if !ok {
+		s = &moments{m: nn.NewMatrixLike(p.Value), v: nn.NewMatrixLike(p.Value), vMax: nn.NewMatrixLike(p.Value)}
+		state[p] = s
+	}
+	return s
+}
+
+// checkBetas the decay rates of an optimizer's moments must be in [0, 1)
+func checkBetas(name string, beta1 float32, beta2 float32) {
+	if beta1 < 0 || beta1 >= 1 || beta2 < 0 || beta2 >= 1 {
+		log.Fatalf("%s betas must be in [0, 1), got %f and %f", name, beta1, beta2)
+	}
+}
@@ -0,0 +1,51 @@
+package optimisers
+
+import (
+	math "github.com/chewxy/math32"
+	"log"
+	"nn-go/nn"
+)
+
+// RMSprop divides each gradient by a running root mean square of recent gradients.
+// Centered subtracts the square of the running mean gradient, normalizing by an
+// estimate of the variance instead, which can be more stable but costs more memory
+type RMSprop struct {
+	learningRate
+	rho      float32
+	epsilon  float32
+	centered bool
+	state    map[*nn.Param]*moments
+}
+
+// NewRMSpropOptimizer create an RMSprop optimizer, Hinton's lecture uses lr 0.001 and rho 0.9,
+// with epsilon 1e-8 commonly added for stability
+func NewRMSpropOptimizer(lr float32, rho float32, epsilon float32, centered bool) *RMSprop {
+	if rho < 0 || rho >= 1 {
+		log.Fatalf("RMSprop rho must be in [0, 1), got %f", rho)
+	}
+	return &RMSprop{
+		learningRate: learningRate{lr},
+		rho:          rho,
+		epsilon:      epsilon,
+		centered:     centered,
+		state:        map[*nn.Param]*moments{},
+	}
+}
+
+func (r *RMSprop) Step(params []*nn.Param) {
+	for _, p := range params {
+		s := momentsOf(r.state, p)
+		each(p.Value, func(i int, j int) {
+			g := p.Grad.Get(i, j)
+			v := r.rho*s.v.Get(i, j) + (1-r.rho)*g*g
+			s.v.Set(i, j, v)
+			if r.centered {
+				m := r.rho*s.m.Get(i, j) + (1-r.rho)*g
+				s.m.Set(i, j, m)
+				// Rounding can take the variance just below 0
+				v = math.Max(v-m*m, 0)
+			}
+			p.Value.Set(i, j, p.Value.Get(i, j)-r.lr*g/(math.Sqrt(v)+r.epsilon))
+		})
+	}
+}
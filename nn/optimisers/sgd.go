@@ -1 +1,64 @@
 package optimisers
+
+import (
+	"log"
+	"nn-go/nn"
+)
+
+// SGD stochastic gradient descent. With momentum each parameter keeps a velocity buffer,
+// v = momentum * v + (1 - dampening) * g, and moves by v, or with Nesterov by g + momentum * v.
+// Weight decay adds weightDecay * value to the gradient first
+type SGD struct {
+	learningRate
+	momentum    float32
+	dampening   float32
+	weightDecay float32
+	nesterov    bool
+	velocity    map[*nn.Param]*nn.Matrix
+}
+
+// NewSGDOptimizer create an SGD optimizer, momentum 0 gives plain gradient descent.
+// Nesterov momentum needs a positive momentum and no dampening
+func NewSGDOptimizer(lr float32, momentum float32, dampening float32, weightDecay float32, nesterov bool) *SGD {
+	if momentum < 0 || weightDecay < 0 {
+		log.Fatalf("SGD momentum and weight decay cannot be negative, got %f and %f", momentum, weightDecay)
+	}
+	if nesterov && (momentum == 0 || dampening != 0) {
+		log.Fatalf("Nesterov momentum needs a positive momentum and no dampening, got %f and %f", momentum, dampening)
+	}
+	return &SGD{
+		learningRate: learningRate{lr},
+		momentum:     momentum,
+		dampening:    dampening,
+		weightDecay:  weightDecay,
+		nesterov:     nesterov,
+		velocity:     map[*nn.Param]*nn.Matrix{},
+	}
+}
+
+func (s *SGD) Step(params []*nn.Param) {
+	for _, p := range params {
+		v, ok := s.velocity[p]
+		if !ok && s.momentum != 0 {
+			v = nn.NewMatrixLike(p.Value)
+			s.velocity[p] = v
+		}
+		each(p.Value, func(i int, j int) {
+			g := p.Grad.Get(i, j) + s.weightDecay*p.Value.Get(i, j)
+			if s.momentum != 0 {
+				// The buffer starts as the first gradient, undamped
+				velocity := g
+				if ok {
+					velocity = s.momentum*v.Get(i, j) + (1-s.dampening)*g
+				}
+				v.Set(i, j, velocity)
+				if s.nesterov {
+					g += s.momentum * velocity
+				} else {
+					g = velocity
+				}
+			}
+			p.Value.Set(i, j, p.Value.Get(i, j)-s.lr*g)
+		})
+	}
+}
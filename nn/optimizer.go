@@ -1,7 +1,10 @@
 package nn
 
+// Optimizer updates parameters from their gradients. Any state it keeps between steps,
+// such as moments or step counts, is kept per parameter
 type Optimizer interface {
-	Call(layer Layer) float32
-	Update(epoch int, results TrainingResults)
+	// Step update the value of every parameter in place from its accumulated gradient
+	Step(params []*Param)
 	Lr() float32
+	SetLr(lr float32)
 }
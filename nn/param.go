@@ -0,0 +1,63 @@
+package nn
+
+import "log"
+
+// Param a named matrix of trainable values in a layer. Backward adds the gradient of the loss
+// with respect to Value to Grad, and an Optimizer updates Value in place from Grad
+type Param struct {
+	Name  string
+	Value *Matrix
+	Grad  *Matrix
+}
+
+func NewParam(name string, value *Matrix) *Param {
+	return &Param{name, value, NewMatrixLike(value)}
+}
+
+// ZeroGrad clear the gradient accumulated by Backward
+func (p *Param) ZeroGrad() {
+	p.Grad.Fill(0)
+}
+
+// ParamLayer is implemented by layers with trainable parameters
+type ParamLayer interface {
+	Params() []*Param
+}
+
+// FlattenValues the values of params concatenated into one vector, row by row
+func FlattenValues(params []*Param) []float32 {
+	var flat []float32
+	for _, p := range params {
+		for _, row := range p.Value.v {
+			flat = append(flat, row...)
+		}
+	}
+	return flat
+}
+
+// FlattenGrads the gradients of params concatenated into one vector, in the order of FlattenValues
+func FlattenGrads(params []*Param) []float32 {
+	var flat []float32
+	for _, p := range params {
+		for _, row := range p.Grad.v {
+			flat = append(flat, row...)
+		}
+	}
+	return flat
+}
+
+// SetValues copy a vector from FlattenValues back into the values of params
+func SetValues(params []*Param, flat []float32) {
+	offset := 0
+	for _, p := range params {
+		for _, row := range p.Value.v {
+			if offset+len(row) > len(flat) {
+				log.Fatalf("Flattened values are too short for the params, got %d", len(flat))
+			}
+			offset += copy(row, flat[offset:])
+		}
+	}
+	if offset != len(flat) {
+		log.Fatalf("Flattened values have %d values, params have %d", len(flat), offset)
+	}
+}
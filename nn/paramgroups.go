@@ -0,0 +1,109 @@
+package nn
+
+import (
+	"log"
+	"strings"
+)
+
+// ParamGroups an Optimizer that updates groups of params with optimizers of their own, so each group
+// has its own learning rate, weight decay and momentum, e.g. a low learning rate for the early layers
+// of a pretrained model and a higher one for a new head. Params in no group use the default optimizer.
+// Select params with Model.LayerParams or Model.NamedLayerParams
+type ParamGroups struct {
+	defaultOptimizer Optimizer
+	baseLr           float32
+	groups           []*paramGroup
+}
+
+// paramGroup params updated by optimizer, whose learning rate started at baseLr
+type paramGroup struct {
+	params    map[*Param]bool
+	optimizer Optimizer
+	baseLr    float32
+}
+
+// NewParamGroups create param groups falling back to defaultOptimizer
+func NewParamGroups(defaultOptimizer Optimizer) *ParamGroups {
+	if defaultOptimizer.Lr() <= 0 {
+		log.Fatalf("Default optimizer must have a positive learning rate, got %f", defaultOptimizer.Lr())
+	}
+	return &ParamGroups{defaultOptimizer: defaultOptimizer, baseLr: defaultOptimizer.Lr()}
+}
+
+// AddGroup update params with optimizer. A param can only be in one group
+func (g *ParamGroups) AddGroup(params []*Param, optimizer Optimizer) *ParamGroups {
+	group := &paramGroup{params: map[*Param]bool{}, optimizer: optimizer, baseLr: optimizer.Lr()}
+	for _, p := range params {
+		if g.groupOf(p) != nil {
+			log.Fatalf("Param %s is already in a group", p.Name)
+		}
+		group.params[p] = true
+	}
+	g.groups = append(g.groups, group)
+	return g
+}
+
+func (g *ParamGroups) groupOf(p *Param) *paramGroup {
+	for _, group := range g.groups {
+		if group.params[p] {
+			return group
+		}
+	}
+	return nil
+}
+
+// Step update each group of params with its optimizer
+func (g *ParamGroups) Step(params []*Param) {
+	grouped := map[*paramGroup][]*Param{}
+	var rest []*Param
+	for _, p := range params {
+		if group := g.groupOf(p); group != nil {
+			grouped[group] = append(grouped[group], p)
+		} else {
+			rest = append(rest, p)
+		}
+	}
+	for _, group := range g.groups {
+		if len(grouped[group]) > 0 {
+			group.optimizer.Step(grouped[group])
+		}
+	}
+	if len(rest) > 0 {
+		g.defaultOptimizer.Step(rest)
+	}
+}
+
+// Lr the learning rate of the default optimizer
+func (g *ParamGroups) Lr() float32 {
+	return g.defaultOptimizer.Lr()
+}
+
+// SetLr set the learning rate of the default optimizer, and scale those of the groups by the same
+// factor from where they started, so a Scheduler keeps the ratios between the groups
+func (g *ParamGroups) SetLr(lr float32) {
+	g.defaultOptimizer.SetLr(lr)
+	for _, group := range g.groups {
+		group.optimizer.SetLr(group.baseLr * lr / g.baseLr)
+	}
+}
+
+// DecayExempt whether weight decay should usually be left off p: biases, named bias or ending in
+// _bias, and normalization scales and shifts, named gamma or beta. Sub-layer prefixes are ignored
+func DecayExempt(p *Param) bool {
+	name := p.Name[strings.LastIndex(p.Name, "/")+1:]
+	return name == "bias" || strings.HasSuffix(name, "_bias") || name == "gamma" || name == "beta"
+}
+
+// SplitDecay split params into those to apply weight decay to, and those exempt from it,
+// see DecayExempt. Put the exempt params in a group whose optimizer has no weight decay
+func SplitDecay(params []*Param) ([]*Param, []*Param) {
+	var decay, exempt []*Param
+	for _, p := range params {
+		if DecayExempt(p) {
+			exempt = append(exempt, p)
+		} else {
+			decay = append(decay, p)
+		}
+	}
+	return decay, exempt
+}
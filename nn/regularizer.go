@@ -0,0 +1,29 @@
+package nn
+
+// Regularizer a penalty on a matrix of weights or activations that is added to the loss,
+// discouraging e.g. large or dense weights. Gradient is the gradient of Penalty with respect to m
+type Regularizer interface {
+	Penalty(m *Matrix) float32
+	Gradient(m *Matrix) *Matrix
+}
+
+// Constraint projects a matrix of weights, in place, back into the set of values it allows.
+// Model.Step applies constraints after every update
+type Constraint interface {
+	Apply(m *Matrix)
+}
+
+// RegularizedLayer is implemented by layers with regularizers. Penalty is the total penalty of the
+// layer given a batch of its input and output, which Train adds to the loss. The layer's Backward
+// includes the gradient of the penalty unless SetPenaltyGradients turns it off
+type RegularizedLayer interface {
+	Layer
+	Penalty(input *Matrix, output *Matrix) float32
+	SetPenaltyGradients(enabled bool)
+}
+
+// ConstrainedLayer is implemented by layers with constraints on their weights, Constrain applies them
+type ConstrainedLayer interface {
+	Layer
+	Constrain()
+}
@@ -0,0 +1,107 @@
+package regularizers
+
+import (
+	math "github.com/chewxy/math32"
+	"log"
+	"nn-go/nn"
+)
+
+// apply fn to every value of m, returning a new matrix
+func apply(m *nn.Matrix, fn func(x float32) float32) *nn.Matrix {
+	out := nn.NewMatrixLike(m)
+	for i := 0; i < m.Rows(); i++ {
+		for j := 0; j < m.Cols(); j++ {
+			out.Set(i, j, fn(m.Get(i, j)))
+		}
+	}
+	return out
+}
+
+// sum fn of every value of m
+func sum(m *nn.Matrix, fn func(x float32) float32) float32 {
+	var total float32
+	for i := 0; i < m.Rows(); i++ {
+		for j := 0; j < m.Cols(); j++ {
+			total += fn(m.Get(i, j))
+		}
+	}
+	return total
+}
+
+func sign(x float32) float32 {
+	if x > 0 {
+		return 1
+	} else if x < 0 {
+		return -1
+	}
+	return 0
+}
+
+// L1L2 l1 * sum(|w|) + l2 * sum(w^2). The L1 term pushes weights to exactly 0, the L2 term
+// keeps them small
+type L1L2 struct {
+	l1 float32
+	l2 float32
+}
+
+func NewL1L2Regularizer(l1 float32, l2 float32) L1L2 {
+	if l1 < 0 || l2 < 0 {
+		log.Fatalf("Regularization factors cannot be negative, got %f and %f", l1, l2)
+	}
+	return L1L2{l1, l2}
+}
+
+// NewL1Regularizer l * sum(|w|)
+func NewL1Regularizer(l float32) L1L2 {
+	return NewL1L2Regularizer(l, 0)
+}
+
+// NewL2Regularizer l * sum(w^2)
+func NewL2Regularizer(l float32) L1L2 {
+	return NewL1L2Regularizer(0, l)
+}
+
+func (r L1L2) Penalty(m *nn.Matrix) float32 {
+	return sum(m, func(x float32) float32 {
+		return r.l1*math.Abs(x) + r.l2*x*x
+	})
+}
+
+func (r L1L2) Gradient(m *nn.Matrix) *nn.Matrix {
+	return apply(m, func(x float32) float32 {
+		return r.l1*sign(x) + 2*r.l2*x
+	})
+}
+
+// Orthogonal factor * ||W^T W - I||^2, encouraging the columns of W, e.g. the weights of each unit of
+// a Dense kernel, to be orthonormal so units don't learn the same features
+type Orthogonal struct {
+	factor float32
+}
+
+func NewOrthogonalRegularizer(factor float32) Orthogonal {
+	if factor < 0 {
+		log.Fatalf("Regularization factor cannot be negative, got %f", factor)
+	}
+	return Orthogonal{factor}
+}
+
+// residual W^T W - I
+func residual(m *nn.Matrix) *nn.Matrix {
+	r := m.T().Product(m)
+	for i := 0; i < r.Rows(); i++ {
+		r.Set(i, i, r.Get(i, i)-1)
+	}
+	return r
+}
+
+func (o Orthogonal) Penalty(m *nn.Matrix) float32 {
+	return o.factor * sum(residual(m), func(x float32) float32 {
+		return x * x
+	})
+}
+
+// Gradient 4 * factor * W (W^T W - I)
+func (o Orthogonal) Gradient(m *nn.Matrix) *nn.Matrix {
+	return m.Product(residual(m)).Multn(4 * o.factor)
+}
@@ -0,0 +1,17 @@
+package nn
+
+// ScheduleInterval how often a Scheduler is stepped during training
+type ScheduleInterval int
+
+const (
+	PerEpoch ScheduleInterval = iota
+	PerBatch                  // After every weight update, i.e. every accumulated batch
+)
+
+// Scheduler sets the learning rate of an optimizer as training progresses. Train calls Step with
+// step 0 before the first batch, then after every batch or epoch according to Interval with how many
+// have been completed. history holds the epochs completed so far
+type Scheduler interface {
+	Interval() ScheduleInterval
+	Step(optimizer Optimizer, step int, history History)
+}
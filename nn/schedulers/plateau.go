@@ -0,0 +1,66 @@
+package schedulers
+
+import (
+	"log"
+	"nn-go/nn"
+)
+
+// ReduceLROnPlateau multiplies the learning rate by factor when the test loss hasn't improved by
+// more than minDelta for patience epochs, then waits cooldown epochs before watching again.
+// The learning rate never goes below minLr. It always steps per epoch
+type ReduceLROnPlateau struct {
+	factor   float32
+	patience int
+	minDelta float32
+	cooldown int
+	minLr    float32
+	best     float32
+	wait     int
+	cooling  int
+	seen     int
+}
+
+// NewReduceLROnPlateau create a plateau schedule, the usual defaults are factor 0.1,
+// patience 10, minDelta 1e-4, cooldown 0 and minLr 0
+func NewReduceLROnPlateau(factor float32, patience int, minDelta float32, cooldown int, minLr float32) *ReduceLROnPlateau {
+	if factor <= 0 || factor >= 1 {
+		log.Fatalf("Plateau factor must be in (0, 1), got %f", factor)
+	}
+	if patience < 0 || cooldown < 0 {
+		log.Fatalf("Plateau patience and cooldown cannot be negative, got %d and %d", patience, cooldown)
+	}
+	return &ReduceLROnPlateau{factor: factor, patience: patience, minDelta: minDelta, cooldown: cooldown, minLr: minLr}
+}
+
+func (r *ReduceLROnPlateau) Interval() nn.ScheduleInterval {
+	return nn.PerEpoch
+}
+
+func (r *ReduceLROnPlateau) Step(optimizer nn.Optimizer, _ int, history nn.History) {
+	losses := history.ValidationLoss
+	// Only react to epochs that haven't been seen yet
+	if len(losses) == r.seen {
+		return
+	}
+	r.seen = len(losses)
+	loss := losses[len(losses)-1]
+	if r.seen == 1 || loss < r.best-r.minDelta {
+		r.best = loss
+		r.wait = 0
+		return
+	}
+	if r.cooling > 0 {
+		r.cooling--
+		return
+	}
+	r.wait++
+	if r.wait >= r.patience {
+		lr := optimizer.Lr() * r.factor
+		if lr < r.minLr {
+			lr = r.minLr
+		}
+		optimizer.SetLr(lr)
+		r.wait = 0
+		r.cooling = r.cooldown
+	}
+}
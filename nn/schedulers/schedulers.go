@@ -0,0 +1,190 @@
+package schedulers
+
+import (
+	math "github.com/chewxy/math32"
+	"log"
+	"nn-go/nn"
+)
+
+// schedule what the schedulers have in common
+type schedule struct {
+	interval nn.ScheduleInterval
+	baseLr   float32
+	started  bool
+}
+
+func (s *schedule) Interval() nn.ScheduleInterval {
+	return s.interval
+}
+
+// base the learning rate of optimizer before the schedule first changed it
+func (s *schedule) base(optimizer nn.Optimizer) float32 {
+	if !s.started {
+		s.baseLr = optimizer.Lr()
+		s.started = true
+	}
+	return s.baseLr
+}
+
+func checkInterval(interval nn.ScheduleInterval) {
+	if interval != nn.PerEpoch && interval != nn.PerBatch {
+		log.Fatalf("Unknown schedule interval %d", interval)
+	}
+}
+
+// StepDecay multiplies the learning rate by gamma every stepSize steps
+type StepDecay struct {
+	schedule
+	stepSize int
+	gamma    float32
+}
+
+func NewStepDecay(stepSize int, gamma float32, interval nn.ScheduleInterval) *StepDecay {
+	checkInterval(interval)
+	if stepSize < 1 {
+		log.Fatalf("Step decay step size must be at least 1, got %d", stepSize)
+	}
+	return &StepDecay{schedule: schedule{interval: interval}, stepSize: stepSize, gamma: gamma}
+}
+
+func (s *StepDecay) Step(optimizer nn.Optimizer, step int, _ nn.History) {
+	optimizer.SetLr(s.base(optimizer) * math.Pow(s.gamma, float32(step/s.stepSize)))
+}
+
+// ExponentialDecay lr * rate^(step / decaySteps), decaying smoothly by rate every decaySteps steps
+type ExponentialDecay struct {
+	schedule
+	rate       float32
+	decaySteps int
+}
+
+func NewExponentialDecay(rate float32, decaySteps int, interval nn.ScheduleInterval) *ExponentialDecay {
+	checkInterval(interval)
+	if decaySteps < 1 {
+		log.Fatalf("Exponential decay steps must be at least 1, got %d", decaySteps)
+	}
+	return &ExponentialDecay{schedule: schedule{interval: interval}, rate: rate, decaySteps: decaySteps}
+}
+
+func (e *ExponentialDecay) Step(optimizer nn.Optimizer, step int, _ nn.History) {
+	optimizer.SetLr(e.base(optimizer) * math.Pow(e.rate, float32(step)/float32(e.decaySteps)))
+}
+
+// CosineAnnealing follows half a cosine from the learning rate down to minLr over period steps, then
+// restarts from the learning rate with the period multiplied by periodMult (SGDR). For a single anneal
+// without restarts use the total number of steps as the period
+type CosineAnnealing struct {
+	schedule
+	period     int
+	periodMult int
+	minLr      float32
+}
+
+func NewCosineAnnealing(period int, periodMult int, minLr float32, interval nn.ScheduleInterval) *CosineAnnealing {
+	checkInterval(interval)
+	if period < 1 || periodMult < 1 {
+		log.Fatalf("Cosine annealing period and multiplier must be at least 1, got %d and %d", period, periodMult)
+	}
+	return &CosineAnnealing{schedule: schedule{interval: interval}, period: period, periodMult: periodMult, minLr: minLr}
+}
+
+func (c *CosineAnnealing) Step(optimizer nn.Optimizer, step int, _ nn.History) {
+	base := c.base(optimizer)
+	// Find how far into the current cycle step is
+	period := c.period
+	for step >= period {
+		step -= period
+		period *= c.periodMult
+	}
+	progress := float32(step) / float32(period)
+	optimizer.SetLr(c.minLr + (base-c.minLr)*(1+math.Cos(math.Pi*progress))/2)
+}
+
+// OneCycle anneals the learning rate up from maxLr / divFactor to maxLr over the first pctStart of
+// totalSteps, then down to maxLr / (divFactor * finalDivFactor) over the rest, both along a cosine.
+// The optimizer's own learning rate is ignored. It is usually stepped per batch
+type OneCycle struct {
+	schedule
+	maxLr          float32
+	totalSteps     int
+	pctStart       float32
+	divFactor      float32
+	finalDivFactor float32
+}
+
+// NewOneCycle create a one cycle schedule, the usual defaults are pctStart 0.3, divFactor 25
+// and finalDivFactor 1e4
+func NewOneCycle(
+	maxLr float32,
+	totalSteps int,
+	pctStart float32,
+	divFactor float32,
+	finalDivFactor float32,
+	interval nn.ScheduleInterval) *OneCycle {
+	checkInterval(interval)
+	if totalSteps < 2 {
+		log.Fatalf("One cycle needs at least 2 steps, got %d", totalSteps)
+	}
+	if pctStart <= 0 || pctStart >= 1 {
+		log.Fatalf("One cycle pctStart must be in (0, 1), got %f", pctStart)
+	}
+	return &OneCycle{
+		schedule:       schedule{interval: interval},
+		maxLr:          maxLr,
+		totalSteps:     totalSteps,
+		pctStart:       pctStart,
+		divFactor:      divFactor,
+		finalDivFactor: finalDivFactor,
+	}
+}
+
+// anneal along a cosine from start to end as progress goes from 0 to 1
+func anneal(start float32, end float32, progress float32) float32 {
+	return end + (start-end)*(1+math.Cos(math.Pi*progress))/2
+}
+
+func (o *OneCycle) Step(optimizer nn.Optimizer, step int, _ nn.History) {
+	initial := o.maxLr / o.divFactor
+	final := initial / o.finalDivFactor
+	last := float32(o.totalSteps - 1)
+	peak := math.Max(1, math.Round(o.pctStart*last))
+	s := math.Min(float32(step), last)
+	if s <= peak {
+		optimizer.SetLr(anneal(initial, o.maxLr, s/peak))
+	} else {
+		optimizer.SetLr(anneal(o.maxLr, final, (s-peak)/(last-peak)))
+	}
+}
+
+// LinearWarmup raises the learning rate linearly from startFactor times it to all of it over
+// the first steps steps, then hands over to then, or keeps the learning rate if then is nil
+type LinearWarmup struct {
+	schedule
+	steps       int
+	startFactor float32
+	then        nn.Scheduler
+}
+
+func NewLinearWarmup(steps int, startFactor float32, then nn.Scheduler, interval nn.ScheduleInterval) *LinearWarmup {
+	checkInterval(interval)
+	if steps < 1 {
+		log.Fatalf("Warmup must last at least 1 step, got %d", steps)
+	}
+	if then != nil && then.Interval() != interval {
+		log.Fatal("Warmup must step at the same interval as the schedule it hands over to")
+	}
+	return &LinearWarmup{schedule: schedule{interval: interval}, steps: steps, startFactor: startFactor, then: then}
+}
+
+func (w *LinearWarmup) Step(optimizer nn.Optimizer, step int, history nn.History) {
+	base := w.base(optimizer)
+	if step < w.steps {
+		optimizer.SetLr(base * (w.startFactor + (1-w.startFactor)*float32(step)/float32(w.steps)))
+		return
+	}
+	// The next schedule starts from the full learning rate
+	optimizer.SetLr(base)
+	if w.then != nil {
+		w.then.Step(optimizer, step-w.steps, history)
+	}
+}
@@ -0,0 +1,65 @@
+package nn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Shape the dimensions of a single sample, not counting the batch. Each row of a
+// Matrix holds one sample with its values flattened in row-major order, so a
+// sequence of shape (timesteps, features) has timestep t in the columns
+// [t*features, (t+1)*features)
+type Shape []int
+
+// Size the number of values in a sample of this shape
+func (s Shape) Size() int {
+	size := 1
+	for _, d := range s {
+		size *= d
+	}
+	return size
+}
+
+// Last the size of the innermost dimension
+func (s Shape) Last() int {
+	return s[len(s)-1]
+}
+
+// Valid returns true if the shape has at least one dimension and every dimension is positive
+func (s Shape) Valid() bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, d := range s {
+		if d < 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal returns true if both shapes have the same dimensions
+func (s Shape) Equal(o Shape) bool {
+	if len(s) != len(o) {
+		return false
+	}
+	for i := range s {
+		if s[i] != o[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Copy a shape
+func (s Shape) Copy() Shape {
+	return append(Shape{}, s...)
+}
+
+func (s Shape) String() string {
+	dims := make([]string, len(s))
+	for i, d := range s {
+		dims[i] = fmt.Sprint(d)
+	}
+	return "(" + strings.Join(dims, ", ") + ")"
+}
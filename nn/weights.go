@@ -0,0 +1,65 @@
+package nn
+
+import (
+	"log"
+)
+
+// classOf the class of sample i. Labels with several columns are one-hot (or soft) and the
+// class is the largest column, a single column holds the class index, e.g. 0 or 1 for binary labels
+func classOf(labels *Matrix, i int) int {
+	if labels.cols > 1 {
+		return argMaxRow(labels.v[i])
+	}
+	c := int(labels.v[i][0])
+	if c < 0 || float32(c) != labels.v[i][0] {
+		log.Fatalf("Label %v of sample %d is not a class index", labels.v[i][0], i)
+	}
+	return c
+}
+
+// countClasses the number of samples in each class
+func countClasses(labels *Matrix) []int {
+	counts := make([]int, labels.cols)
+	for i := 0; i < labels.rows; i++ {
+		c := classOf(labels, i)
+		for c >= len(counts) {
+			counts = append(counts, 0)
+		}
+		counts[c]++
+	}
+	if labels.cols == 1 && len(counts) < 2 {
+		counts = append(counts, 0)
+	}
+	return counts
+}
+
+// BalancedClassWeights weights each class by n / (classes * count) so that every class
+// contributes equally to the loss. Classes missing from labels get a weight of 1
+func BalancedClassWeights(labels *Matrix) []float32 {
+	counts := countClasses(labels)
+	weights := make([]float32, len(counts))
+	for c, count := range counts {
+		weights[c] = 1
+		if count > 0 {
+			weights[c] = float32(labels.rows) / float32(len(counts)*count)
+		}
+	}
+	return weights
+}
+
+// ClassSampleWeights the weight of each sample's class as a (batch, 1) column,
+// multiplied into sampleWeights when they are given
+func ClassSampleWeights(labels *Matrix, classWeights []float32, sampleWeights *Matrix) *Matrix {
+	out := NewMatrix(labels.rows, 1)
+	for i := 0; i < labels.rows; i++ {
+		c := classOf(labels, i)
+		if c >= len(classWeights) {
+			log.Fatalf("Sample %d has class %d but only %d class weights were given", i, c, len(classWeights))
+		}
+		out.v[i][0] = classWeights[c]
+	}
+	if sampleWeights != nil {
+		out.Mult(checkWeights(sampleWeights, labels.rows))
+	}
+	return out
+}
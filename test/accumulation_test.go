@@ -0,0 +1,53 @@
+package test
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"nn-go/nn/loss"
+	"testing"
+)
+
+// accumulate train a zero initialised classifier for an epoch on data, returning it and the results
+func accumulate(data nn.TrainTestSet, batchSize int, steps int) (*nn.Model, nn.History) {
+	model := nn.NewModel(nn.Shape{2}, &loss.CategoricalCrossEntropy{}, &fixedLr{1})
+	model.
+		AddLayer(layers.NewDenseLayer(3, true, activations.Linear, initializers.Zero{}, initializers.Zero{})).
+		AddLayer(layers.NewSoftmaxLayer(3))
+	model.Init()
+	results := model.Train(nn.NewTrainArgs(&data, nil, 1, batchSize, false).WithAccumulationSteps(steps))
+	return model, results
+}
+
+func TestTrain_AccumulationSteps(t *testing.T) {
+	data := nn.TrainTestSet{Train: blobs(6), Test: blobs(3)}
+	whole, wholeResults := accumulate(data, 6, 1)
+	accumulated, accumulatedResults := accumulate(data, 2, 3)
+	if len(accumulatedResults.GradientNorms) != 1 {
+		t.Fatalf("3 accumulated batches should make 1 update, got %d", len(accumulatedResults.GradientNorms))
+	}
+	if math.Abs(accumulatedResults.GradientNorms[0]-wholeResults.GradientNorms[0]) > 1e-5 {
+		t.Errorf("Accumulated gradient norm should be %v, got %v",
+			wholeResults.GradientNorms[0], accumulatedResults.GradientNorms[0])
+	}
+	wholeParams, accumulatedParams := whole.Params(), accumulated.Params()
+	for i, p := range wholeParams {
+		for r := 0; r < p.Value.Rows(); r++ {
+			for c := 0; c < p.Value.Cols(); c++ {
+				if math.Abs(p.Value.Get(r, c)-accumulatedParams[i].Value.Get(r, c)) > 1e-5 {
+					t.Errorf("%s after accumulating should be %v, got %v", p.Name, p.Value, accumulatedParams[i].Value)
+				}
+			}
+		}
+	}
+}
+
+func TestTrain_AccumulationRemainder(t *testing.T) {
+	// 3 batches in steps of 2, the last update uses the 1 batch left over
+	_, results := accumulate(nn.TrainTestSet{Train: blobs(9), Test: blobs(3)}, 3, 2)
+	if len(results.GradientNorms) != 2 {
+		t.Errorf("Should make 2 updates, got %d", len(results.GradientNorms))
+	}
+}
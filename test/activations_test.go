@@ -0,0 +1,78 @@
+package test
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"testing"
+)
+
+func TestActivations_Derivative(t *testing.T) {
+	fns := map[string]nn.Activator{
+		"ReLU":        activations.ReLU,
+		"Linear":      activations.Linear,
+		"Sigmoid":     activations.Sigmoid,
+		"Tanh":        activations.Tanh,
+		"LeakyReLU":   activations.NewLeakyReLU(0.2),
+		"GELU":        activations.GELU,
+		"SiLU":        activations.SiLU,
+		"Swish":       activations.NewSwish(1.5),
+		"ELU":         activations.NewELU(1.2),
+		"SELU":        activations.SELU,
+		"Softplus":    activations.Softplus,
+		"Softsign":    activations.Softsign,
+		"HardSigmoid": activations.HardSigmoid,
+		"HardSwish":   activations.HardSwish,
+		"Mish":        activations.Mish,
+	}
+	const eps = 1e-3
+	for name, fn := range fns {
+		for _, x := range []float32{-3.7, -1.3, -0.4, 0.6, 1.7, 3.4} {
+			numeric := (fn.Forward(x+eps) - fn.Forward(x-eps)) / (2 * eps)
+			analytic := fn.Derivative(x, fn.Forward(x))
+			if math.Abs(numeric-analytic) > 1e-2 {
+				t.Errorf("%s derivative at %.1f is %.4f, numerically %.4f", name, x, analytic, numeric)
+			}
+		}
+	}
+}
+
+func TestActivations_Values(t *testing.T) {
+	cases := []struct {
+		name string
+		fn   nn.Activator
+		x    float32
+		want float32
+	}{
+		{"LeakyReLU", activations.NewLeakyReLU(0.3), -2, -0.6},
+		{"ELU", activations.NewELU(1), 0, 0},
+		{"SELU", activations.SELU, 1, 1.0507},
+		{"GELU", activations.GELU, 1, 0.8413},
+		{"SiLU", activations.SiLU, 1, 0.7311},
+		{"Softplus", activations.Softplus, 100, 100},
+		{"HardSigmoid", activations.HardSigmoid, 0, 0.5},
+		{"HardSwish", activations.HardSwish, 4, 4},
+		{"Mish", activations.Mish, 1, 0.8651},
+	}
+	for _, c := range cases {
+		if got := c.fn.Forward(c.x); math.Abs(got-c.want) > 1e-3 {
+			t.Errorf("%s(%.1f) = %.4f, want %.4f", c.name, c.x, got, c.want)
+		}
+	}
+}
+
+func TestDense_ActivationGradients(t *testing.T) {
+	for _, fn := range []nn.Activator{activations.Sigmoid, activations.Tanh, activations.GELU} {
+		l := layers.NewDenseLayer(3, true, fn, initializers.Glorot{}, initializers.NewConstInitializer(0.1))
+		l.Init(nn.Shape{4})
+		checkInputGradients(t, l, randomMatrix(3, 4))
+	}
+}
+
+func TestActivationLayer_Gradients(t *testing.T) {
+	l := layers.NewActivationLayer(activations.Mish)
+	l.Init(nn.Shape{5})
+	checkInputGradients(t, l, randomMatrix(2, 5))
+}
@@ -0,0 +1,84 @@
+package test
+
+import (
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"testing"
+)
+
+const (
+	attSteps    = 3
+	attFeatures = 4
+)
+
+func TestMultiHeadAttention_InputGradients(t *testing.T) {
+	for _, causal := range []bool{false, true} {
+		l := layers.NewMultiHeadAttentionLayer(2, 3, causal, initializers.Glorot{}, initializers.NewConstInitializer(0.1))
+		l.Init(nn.Shape{attSteps, attFeatures})
+		l.SetPaddingMask(nn.NewMatrixFromArray([][]float32{{1, 1, 1}, {1, 1, 0}}))
+		checkInputGradients(t, l, randomMatrix(2, attSteps*attFeatures))
+	}
+}
+
+func TestMultiHeadAttention_Weights(t *testing.T) {
+	l := layers.NewMultiHeadAttentionLayer(2, 3, true, initializers.Glorot{}, initializers.Zero{})
+	l.Init(nn.Shape{attSteps, attFeatures})
+	l.SetPaddingMask(nn.NewMatrixFromArray([][]float32{{1, 1, 1}, {1, 1, 0}}))
+	l.Forward(randomMatrix(2, attSteps*attFeatures))
+	weights := l.AttentionWeights()
+	if len(weights) != 2 || len(weights[0]) != 2 {
+		t.Fatalf("Expected attention weights for 2 samples and 2 heads")
+	}
+	for b, heads := range weights {
+		for _, w := range heads {
+			for i := 0; i < attSteps; i++ {
+				if sum := w.SliceRows(i, i+1).Sum(); sum < 0.999 || sum > 1.001 {
+					t.Errorf("Attention row %d sums to %.4f", i, sum)
+				}
+				for j := i + 1; j < attSteps; j++ {
+					if w.Get(i, j) > 1e-6 {
+						t.Errorf("Causal attention from %d to later timestep %d is %.4f", i, j, w.Get(i, j))
+					}
+				}
+				if b == 1 && w.Get(i, 2) > 1e-6 {
+					t.Errorf("Padded timestep 2 has attention %.4f", w.Get(i, 2))
+				}
+			}
+		}
+	}
+}
+
+func TestLayerNormalization_InputGradients(t *testing.T) {
+	l := layers.NewLayerNormalizationLayer(1e-5)
+	l.Init(nn.Shape{attSteps, attFeatures})
+	checkInputGradients(t, l, randomMatrix(2, attSteps*attFeatures))
+}
+
+func TestTransformerEncoderBlock_InputGradients(t *testing.T) {
+	l := layers.NewTransformerEncoderBlock(2, 2, 6, false, activations.GELU, newSeededGlorot(1))
+	if output := l.Init(nn.Shape{attSteps, attFeatures}); !output.Equal(nn.Shape{attSteps, attFeatures}) {
+		t.Fatalf("Encoder block should keep the input shape, got %v", output)
+	}
+	checkInputGradients(t, l, randomMatrix(2, attSteps*attFeatures))
+}
+
+func TestTransformerEncoderBlock_Learns(t *testing.T) {
+	l := layers.NewTransformerEncoderBlock(2, 2, 6, false, activations.GELU, initializers.Glorot{})
+	l.Init(nn.Shape{attSteps, attFeatures})
+	input := randomMatrix(4, attSteps*attFeatures)
+	target := randomMatrix(4, attSteps*attFeatures)
+	loss := func() float32 {
+		diff := l.Forward(input).Sub(target)
+		return diff.Copy().Mult(diff).Sum()
+	}
+	before := loss()
+	for i := 0; i < 30; i++ {
+		l.Backward(input, l.Forward(input).Sub(target).Multn(2))
+		descend(l, 0.01)
+	}
+	if after := loss(); after >= before {
+		t.Errorf("Loss did not decrease, %.4f -> %.4f", before, after)
+	}
+}
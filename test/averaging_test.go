@@ -0,0 +1,104 @@
+package test
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"nn-go/nn/loss"
+	"testing"
+)
+
+func TestEMA_UpdateAndSwap(t *testing.T) {
+	p := scalarParam(1)
+	params := []*nn.Param{p}
+	ema := nn.NewEMA(0.5)
+	ema.Update(params)
+	p.Value.Set(0, 0, 3)
+	ema.Update(params)
+	ema.Swap(params)
+	if got := p.Value.Get(0, 0); got != 2 || !ema.Swapped() {
+		t.Errorf("Swapped in average should be 2, got %v", got)
+	}
+	ema.Swap(params)
+	if got := p.Value.Get(0, 0); got != 3 || ema.Swapped() {
+		t.Errorf("Swapping back should restore 3, got %v", got)
+	}
+}
+
+// statistics counts the rows it has seen since being reset
+type statistics struct {
+	*layers.Activation
+	rows int
+}
+
+func (s *statistics) ResetStatistics() {
+	s.rows = 0
+}
+
+func (s *statistics) UpdateStatistics(input *nn.Matrix) {
+	s.rows += input.Rows()
+}
+
+func TestTrain_SWA(t *testing.T) {
+	data := nn.TrainTestSet{Train: blobs(9), Test: blobs(3)}
+	classifier := func() (*nn.Model, *statistics) {
+		stats := &statistics{Activation: layers.NewActivationLayer(activations.Linear), rows: -1}
+		model := nn.NewModel(nn.Shape{2}, &loss.CategoricalCrossEntropy{}, &fixedLr{0.5})
+		model.
+			AddLayer(stats).
+			AddLayer(layers.NewDenseLayer(3, true, activations.Linear, initializers.Zero{}, initializers.Zero{})).
+			AddLayer(layers.NewSoftmaxLayer(3))
+		model.Init()
+		return model, stats
+	}
+	// The weights after each epoch, training one epoch at a time
+	plain, _ := classifier()
+	var epochs [][]*nn.Matrix
+	for i := 0; i < 3; i++ {
+		plain.Train(nn.NewTrainArgs(&data, nil, 1, 3, false))
+		var values []*nn.Matrix
+		for _, p := range plain.Params() {
+			values = append(values, p.Value.Copy())
+		}
+		epochs = append(epochs, values)
+	}
+
+	averaged, stats := classifier()
+	swa := nn.NewSWA(2)
+	averaged.Train(nn.NewTrainArgs(&data, nil, 3, 3, false).WithSWA(swa))
+	if swa.Count() != 2 {
+		t.Errorf("Should average the last 2 epochs, got %d", swa.Count())
+	}
+	for i, p := range averaged.Params() {
+		want := epochs[1][i].Copy().Add(epochs[2][i]).Divn(2)
+		for r := 0; r < want.Rows(); r++ {
+			for c := 0; c < want.Cols(); c++ {
+				if math.Abs(p.Value.Get(r, c)-want.Get(r, c)) > 1e-5 {
+					t.Fatalf("%s should be the average %v, got %v", p.Name, want, p.Value)
+				}
+			}
+		}
+	}
+	if stats.rows != 9 {
+		t.Errorf("Statistics should be refreshed from the 9 training instances, got %d", stats.rows)
+	}
+
+	// Training on with the same SWA carries on its average rather than averaging the last weights
+	average := averaged.Weights()
+	next, _ := classifier()
+	next.SetWeights(average)
+	next.Train(nn.NewTrainArgs(&data, nil, 1, 3, false))
+	averaged.Train(nn.NewTrainArgs(&data, nil, 1, 3, false).WithSWA(swa))
+	for i, p := range averaged.Params() {
+		want := average[i].Copy().Multn(2).Add(next.Params()[i].Value).Divn(3)
+		for r := 0; r < want.Rows(); r++ {
+			for c := 0; c < want.Cols(); c++ {
+				if math.Abs(p.Value.Get(r, c)-want.Get(r, c)) > 1e-5 {
+					t.Fatalf("%s should be the average of 3 epochs %v, got %v", p.Name, want, p.Value)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/callbacks"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"nn-go/nn/loss"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// callbackModel a zero initialised classifier and 3 batches of blobs to train it on
+func callbackModel(optimizer nn.Optimizer) (*nn.Model, *nn.TrainTestSet) {
+	model := nn.NewModel(nn.Shape{2}, &loss.CategoricalCrossEntropy{}, optimizer)
+	model.
+		AddLayer(layers.NewDenseLayer(3, true, activations.Linear, initializers.Zero{}, initializers.Zero{})).
+		AddLayer(layers.NewSoftmaxLayer(3))
+	model.Init()
+	return model, &nn.TrainTestSet{Train: blobs(9), Test: blobs(3)}
+}
+
+func TestCallbacks_Order(t *testing.T) {
+	model, data := callbackModel(&fixedLr{0.1})
+	var events []string
+	record := callbacks.LambdaCallback{
+		TrainBegin: func(_ *nn.Model, logs nn.Logs) { events = append(events, fmt.Sprintf("train %v", logs["epochs"])) },
+		EpochBegin: func(_ *nn.Model, epoch int, _ nn.Logs) { events = append(events, fmt.Sprintf("epoch %d", epoch)) },
+		BatchEnd: func(_ *nn.Model, batch int, logs nn.Logs) {
+			if _, ok := logs["loss"]; ok {
+				events = append(events, fmt.Sprintf("batch %d", batch))
+			}
+		},
+		EpochEnd: func(_ *nn.Model, epoch int, logs nn.Logs) {
+			if _, ok := logs["val_loss"]; ok {
+				events = append(events, fmt.Sprintf("end %d", epoch))
+			}
+		},
+		TrainEnd: func(*nn.Model, nn.Logs) { events = append(events, "done") },
+	}
+	model.Train(nn.NewTrainArgs(data, nil, 2, 3, false).WithCallbacks(record))
+	want := "train 2,epoch 0,batch 0,batch 1,batch 2,end 0,epoch 1,batch 0,batch 1,batch 2,end 1,done"
+	if got := strings.Join(events, ","); got != want {
+		t.Errorf("Callbacks should be called in order\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestCallbacks_StopTraining(t *testing.T) {
+	model, data := callbackModel(&fixedLr{0.1})
+	stop := callbacks.LambdaCallback{BatchEnd: func(m *nn.Model, batch int, _ nn.Logs) {
+		if batch == 1 {
+			m.StopTraining()
+		}
+	}}
+	history := model.Train(nn.NewTrainArgs(data, nil, 5, 3, false).WithCallbacks(stop))
+	if history.Epochs() != 1 || len(history.BatchLoss) != 2 {
+		t.Errorf("Should stop after 2 batches of the first epoch, got %d epochs and %d batches",
+			history.Epochs(), len(history.BatchLoss))
+	}
+
+	// A NaN learning rate makes the weights NaN after the first update
+	model, data = callbackModel(&fixedLr{math.NaN()})
+	history = model.Train(nn.NewTrainArgs(data, nil, 5, 3, false).WithCallbacks(callbacks.TerminateOnNaN{}))
+	if len(history.BatchLoss) != 2 {
+		t.Errorf("Should terminate on the first NaN loss, got %v", history.BatchLoss)
+	}
+}
+
+func TestEarlyStopping(t *testing.T) {
+	model, data := callbackModel(&fixedLr{0.1})
+	var first []*nn.Matrix
+	snapshot := callbacks.LambdaCallback{EpochEnd: func(m *nn.Model, epoch int, _ nn.Logs) {
+		if epoch == 0 {
+			first = m.Weights()
+		}
+	}}
+	// No later epoch improves the loss by 10, so it stops after patience more
+	early := callbacks.NewEarlyStopping("val_loss", callbacks.Auto, 10, 2, true)
+	history := model.Train(nn.NewTrainArgs(data, nil, 10, 3, false).WithCallbacks(snapshot, early))
+	if history.Epochs() != 3 || early.StoppedEpoch != 2 || early.BestEpoch() != 0 {
+		t.Errorf("Should stop after 3 epochs at epoch 2 with the best 0, got %d, %d and %d",
+			history.Epochs(), early.StoppedEpoch, early.BestEpoch())
+	}
+	for i, w := range model.Weights() {
+		if !w.Eq(first[i]).All() {
+			t.Errorf("Should restore the weights of the first epoch")
+		}
+	}
+}
+
+func TestEarlyStopping_Mode(t *testing.T) {
+	model, _ := callbackModel(&fixedLr{0.1})
+	// auc improves every epoch, which only Max knows from its name
+	for _, c := range []struct {
+		mode    callbacks.Mode
+		stopped int
+	}{{callbacks.Auto, 2}, {callbacks.Min, 2}, {callbacks.Max, -1}} {
+		early := callbacks.NewEarlyStopping("auc", c.mode, 0, 2, false)
+		early.OnTrainBegin(model, nn.Logs{})
+		for epoch, auc := range []float32{0.5, 0.6, 0.7, 0.8} {
+			early.OnEpochEnd(model, epoch, nn.Logs{"auc": auc})
+			if early.StoppedEpoch >= 0 {
+				break
+			}
+		}
+		if early.StoppedEpoch != c.stopped {
+			t.Errorf("Mode %d should stop at epoch %d, got %d", c.mode, c.stopped, early.StoppedEpoch)
+		}
+	}
+}
+
+func TestModelCheckpoint(t *testing.T) {
+	model, data := callbackModel(&fixedLr{0.1})
+	dir := t.TempDir()
+	checkpoint := callbacks.NewModelCheckpoint(filepath.Join(dir, "100%-weights-%d.gob"), "val_loss", callbacks.Min, false)
+	model.Train(nn.NewTrainArgs(data, nil, 2, 3, false).WithCallbacks(checkpoint))
+	f, err := os.Open(filepath.Join(dir, "100%-weights-2.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	restored, _ := callbackModel(&fixedLr{0.1})
+	if err := restored.LoadWeights(f); err != nil {
+		t.Fatal(err)
+	}
+	want := model.Weights()
+	for i, w := range restored.Weights() {
+		if !w.Eq(want[i]).All() {
+			t.Errorf("Checkpoint of the last epoch should have the final weights")
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "100%-weights-1.gob")); err != nil {
+		t.Errorf("Should save every epoch, %v", err)
+	}
+}
+
+func TestCSVLogger_ProgressBar(t *testing.T) {
+	model, data := callbackModel(&fixedLr{0.1})
+	var csv, progress bytes.Buffer
+	model.Train(nn.NewTrainArgs(data, nil, 2, 3, false).
+		WithCallbacks(callbacks.NewCSVLogger(&csv), callbacks.NewProgressBar(&progress)))
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if len(lines) != 3 || lines[0] != "epoch,duration,loss,lr,val_loss" || !strings.HasPrefix(lines[2], "1,") {
+		t.Errorf("CSV should have a header and a row per epoch, got\n%s", csv.String())
+	}
+	if out := progress.String(); !strings.Contains(out, "Epoch 2/2") || !strings.Contains(out, "3/3 [") ||
+		!strings.Contains(out, "val_loss: ") {
+		t.Errorf("Progress bar should show the epochs, batches and logs, got\n%s", out)
+	}
+}
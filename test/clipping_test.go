@@ -0,0 +1,99 @@
+package test
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"nn-go/nn/loss"
+	"testing"
+)
+
+// gradParams params with the given gradients
+func gradParams(grads ...[][]float32) []*nn.Param {
+	params := make([]*nn.Param, len(grads))
+	for i, g := range grads {
+		params[i] = nn.NewParam("p", nn.NewMatrixLike(nn.NewMatrixFromArray(g)))
+		params[i].Grad = nn.NewMatrixFromArray(g)
+	}
+	return params
+}
+
+func checkGrads(t *testing.T, name string, params []*nn.Param, want ...[][]float32) {
+	for i, p := range params {
+		w := nn.NewMatrixFromArray(want[i])
+		for r := 0; r < w.Rows(); r++ {
+			for c := 0; c < w.Cols(); c++ {
+				if math.Abs(p.Grad.Get(r, c)-w.Get(r, c)) > 1e-5 {
+					t.Errorf("%s: param %d gradient should be %v, got %v", name, i, w, p.Grad)
+					return
+				}
+			}
+		}
+	}
+}
+
+func TestGradientClipping_Clip(t *testing.T) {
+	params := gradParams([][]float32{{3, -4}}, [][]float32{{0.5, -10}})
+	nn.GradientClipping{Value: 1}.Clip(params)
+	checkGrads(t, "Value", params, [][]float32{{1, -1}}, [][]float32{{0.5, -1}})
+
+	// Only the param whose norm is over the threshold is rescaled
+	params = gradParams([][]float32{{3, -4}}, [][]float32{{0.6, 0.8}})
+	nn.GradientClipping{Norm: 2.5}.Clip(params)
+	checkGrads(t, "Norm", params, [][]float32{{1.5, -2}}, [][]float32{{0.6, 0.8}})
+
+	// Together the norm is sqrt(9 + 16 + 144) = 13
+	params = gradParams([][]float32{{3, -4}}, [][]float32{{12}})
+	if got := nn.GlobalNorm(params); math.Abs(got-13) > 1e-5 {
+		t.Errorf("Global norm should be 13, got %v", got)
+	}
+	nn.GradientClipping{GlobalNorm: 6.5}.Clip(params)
+	checkGrads(t, "GlobalNorm", params, [][]float32{{1.5, -2}}, [][]float32{{6}})
+
+	params = gradParams([][]float32{{3, -4}})
+	nn.GradientClipping{GlobalNorm: 100}.Clip(params)
+	checkGrads(t, "GlobalNorm under threshold", params, [][]float32{{3, -4}})
+}
+
+func TestClipGradients_Optimizer(t *testing.T) {
+	p := scalarParam(0)
+	clipped := nn.ClipGradients(&fixedLr{1}, nn.GradientClipping{Value: 0.5})
+	if got := stepWith(clipped, p, 10); got != -0.5 {
+		t.Errorf("Clipped step should move to -0.5, got %v", got)
+	}
+	clipped.SetLr(2)
+	if clipped.Lr() != 2 {
+		t.Errorf("Wrapped optimizer should keep its learning rate, got %v", clipped.Lr())
+	}
+}
+
+func TestTrain_GradientNorms(t *testing.T) {
+	train, test := blobs(9), blobs(3)
+	run := func(args func(*nn.TrainArgs) *nn.TrainArgs) nn.History {
+		model := nn.NewModel(nn.Shape{2}, &loss.CategoricalCrossEntropy{}, &fixedLr{1})
+		model.
+			AddLayer(layers.NewDenseLayer(3, true, activations.Linear, initializers.Zero{}, initializers.Zero{})).
+			AddLayer(layers.NewSoftmaxLayer(3))
+		model.Init()
+		data := nn.TrainTestSet{Train: train, Test: test}
+		return model.Train(args(nn.NewTrainArgs(&data, nil, 2, 3, false)))
+	}
+	plain := run(func(a *nn.TrainArgs) *nn.TrainArgs { return a })
+	if len(plain.GradientNorms) != 6 {
+		t.Fatalf("Should report a gradient norm for each of the 6 batches, got %v", plain.GradientNorms)
+	}
+	clipped := run(func(a *nn.TrainArgs) *nn.TrainArgs {
+		return a.WithGradientClipping(nn.GradientClipping{GlobalNorm: 1e-3})
+	})
+	// The first batch is the same, and the reported norm is from before clipping
+	if clipped.GradientNorms[0] != plain.GradientNorms[0] || clipped.GradientNorms[0] <= 1e-3 {
+		t.Errorf("First batch should report the unclipped norm %v, got %v", plain.GradientNorms[0], clipped.GradientNorms[0])
+	}
+	// Tiny clipped steps barely move the weights, so the later gradients stay close to the first
+	if last := clipped.GradientNorms[5]; math.Abs(last-clipped.GradientNorms[0]) > math.Abs(plain.GradientNorms[5]-plain.GradientNorms[0]) {
+		t.Errorf("Clipped training should change the gradient norm less than unclipped, got %v and %v",
+			clipped.GradientNorms, plain.GradientNorms)
+	}
+}
@@ -0,0 +1,109 @@
+package test
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+	"nn-go/nn/loss"
+	"reflect"
+	"testing"
+)
+
+// collapse merge repeats then remove blanks from a CTC alignment
+func collapse(path []int, blank int) []int {
+	out := []int{}
+	for t, k := range path {
+		if k != blank && (t == 0 || path[t-1] != k) {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+func TestCTC_BruteForce(t *testing.T) {
+	const timesteps, classes = 4, 3
+	ctc := loss.NewCTCLoss(classes, 0)
+	logits := randomMatrix(1, timesteps*classes).Multn(2)
+	probs := logits.Reshape(timesteps, classes).Softmax()
+	labels := []int{1, 1}
+	// Sum the probability of every alignment that collapses to the labels
+	var want float32
+	path := make([]int, timesteps)
+	var visit func(t int, p float32)
+	visit = func(t int, p float32) {
+		if t == timesteps {
+			if reflect.DeepEqual(collapse(path, 0), labels) {
+				want += p
+			}
+			return
+		}
+		for k := 0; k < classes; k++ {
+			path[t] = k
+			visit(t+1, p*probs.Get(t, k))
+		}
+	}
+	visit(0, 1)
+	got := ctc.Call(logits, loss.CTCLabels([]int{timesteps}, [][]int{labels}), nil).Get(0, 0)
+	if math.Abs(got+math.Log(want)) > 1e-4 {
+		t.Errorf("CTC loss should be %.5f, got %.5f", -math.Log(want), got)
+	}
+}
+
+func TestCTC_Gradients(t *testing.T) {
+	const timesteps, classes = 5, 4
+	expected := loss.CTCLabels([]int{5, 3, 4}, [][]int{{1, 2, 2}, {3}, {}})
+	for _, r := range []nn.Reduction{nn.ReduceMean, nn.ReduceSum, nn.ReduceNone} {
+		ctc := loss.NewCTCLoss(classes, 0)
+		ctc.Reduction = r
+		checkLossGradients(t, "CTC", ctc, randomMatrix(3, timesteps*classes).Multn(2), expected)
+	}
+	// Timesteps past the input length don't contribute
+	grads := loss.NewCTCLoss(classes, 0).Gradient(randomMatrix(3, timesteps*classes), expected, nil)
+	for j := 3 * classes; j < timesteps*classes; j++ {
+		if grads.Get(1, j) != 0 {
+			t.Fatalf("Padded timesteps should have no gradient, got %v at col %d", grads.Get(1, j), j)
+		}
+	}
+}
+
+func TestCTC_Blank(t *testing.T) {
+	// Moving the blank to the last class and the labels down by one gives the same loss
+	logits := randomMatrix(1, 4*3)
+	shifted := nn.NewMatrix(1, 4*3)
+	for step := 0; step < 4; step++ {
+		shifted.Set(0, step*3+2, logits.Get(0, step*3))
+		shifted.Set(0, step*3, logits.Get(0, step*3+1))
+		shifted.Set(0, step*3+1, logits.Get(0, step*3+2))
+	}
+	want := loss.NewCTCLoss(3, 0).Call(logits, loss.CTCLabels([]int{4}, [][]int{{1, 2}}), nil).Get(0, 0)
+	got := loss.NewCTCLoss(3, 2).Call(shifted, loss.CTCLabels([]int{4}, [][]int{{0, 1}}), nil).Get(0, 0)
+	if math.Abs(got-want) > 1e-5 {
+		t.Errorf("Blank index 2 should give %.5f, got %.5f", want, got)
+	}
+}
+
+func TestCTC_Decode(t *testing.T) {
+	ctc := loss.NewCTCLoss(3, 0)
+	// Timestep logits favouring 1 1 0 1 2 2, the last timestep is padding
+	logits := nn.NewMatrix(1, 7*3)
+	for step, k := range []int{1, 1, 0, 1, 2, 2, 1} {
+		logits.Set(0, step*3+k, 5)
+	}
+	if got := ctc.GreedyDecode(logits, nil); !reflect.DeepEqual(got, [][]int{{1, 1, 2, 1}}) {
+		t.Errorf("Greedy decoding should give [[1 1 2 1]], got %v", got)
+	}
+	if got := ctc.GreedyDecode(logits, []int{6}); !reflect.DeepEqual(got, [][]int{{1, 1, 2}}) {
+		t.Errorf("Greedy decoding of 6 timesteps should give [[1 1 2]], got %v", got)
+	}
+	if got := ctc.BeamSearchDecode(logits, []int{6}, 4); !reflect.DeepEqual(got, [][]int{{1, 1, 2}}) {
+		t.Errorf("Beam search should give [[1 1 2]], got %v", got)
+	}
+	// Blank is the most likely class at both timesteps, but the alignments of "1" add up to more
+	ambiguous := nn.NewMatrixFromArray([][]float32{{math.Log(0.6), math.Log(0.4), math.Log(0.6), math.Log(0.4)}})
+	binary := loss.NewCTCLoss(2, 0)
+	if got := binary.GreedyDecode(ambiguous, nil); !reflect.DeepEqual(got, [][]int{{}}) {
+		t.Errorf("Greedy decoding should give [[]], got %v", got)
+	}
+	if got := binary.BeamSearchDecode(ambiguous, nil, 2); !reflect.DeepEqual(got, [][]int{{1}}) {
+		t.Errorf("Beam search should give [[1]], got %v", got)
+	}
+}
@@ -0,0 +1,77 @@
+package test
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+	"nn-go/nn/loss"
+	"testing"
+)
+
+func TestEmbeddingLosses_Gradients(t *testing.T) {
+	ids := nn.NewMatrixFromArray([][]float32{{0}, {1}, {0}, {2}, {1}, {0}})
+	weights := nn.NewMatrixFromArray([][]float32{{1}, {0.5}, {2}, {1}, {0}, {1}})
+	// Mining is discontinuous where two distances from an anchor are equal, so the
+	// triplets use embeddings whose distances from each anchor are well apart
+	spread := nn.NewMatrixFromArray([][]float32{{0, 0.35}, {0.9, -0.2}, {0.7, 0.5}, {-0.4, 0.3}, {0.1, -0.6}, {-0.3, -0.5}})
+	for _, r := range []nn.Reduction{nn.ReduceMean, nn.ReduceSum, nn.ReduceNone} {
+		contrastive := loss.NewContrastiveLoss(1.5)
+		contrastive.Reduction = r
+		hard := loss.NewTripletLoss(1, loss.BatchHard)
+		hard.Reduction = r
+		semiHard := loss.NewTripletLoss(1, loss.SemiHard)
+		semiHard.Reduction = r
+		ntXent := loss.NewNTXentLoss(0.5)
+		ntXent.Reduction = r
+		for _, w := range []*nn.Matrix{nil, weights} {
+			checkWeightedLossGradients(t, "Contrastive", contrastive, randomMatrix(6, 4), ids, w)
+			checkWeightedLossGradients(t, "BatchHardTriplet", hard, spread, ids, w)
+			checkWeightedLossGradients(t, "SemiHardTriplet", semiHard, spread, ids, w)
+			checkWeightedLossGradients(t, "NTXent", ntXent, randomMatrix(6, 4), ids, w)
+		}
+	}
+}
+
+func TestContrastive_Values(t *testing.T) {
+	embeddings := nn.NewMatrixFromArray([][]float32{{0, 0}, {3, 4}, {0, 1}})
+	ids := nn.NewMatrixFromArray([][]float32{{0}, {0}, {1}})
+	// Pairs: (0, 1) similar at 5, (0, 2) different at 1, (1, 2) different at sqrt(18)
+	// and each pair counts towards both of its samples' losses
+	want := float32(2*(25+1+0)) / 2 / 3
+	if got := loss.NewContrastiveLoss(2).Call(embeddings, ids, nil).Get(0, 0); math.Abs(got-want) > 1e-5 {
+		t.Errorf("Contrastive loss should be %.4f, got %.4f", want, got)
+	}
+}
+
+func TestTriplet_Mining(t *testing.T) {
+	// Anchor 0 has positives at distance 1 and 3, negatives at distance 2 and 4
+	embeddings := nn.NewMatrixFromArray([][]float32{{0}, {1}, {3}, {-2}, {-4}})
+	ids := nn.NewMatrixFromArray([][]float32{{0}, {0}, {0}, {1}, {1}})
+	none := nn.ReduceNone
+	hard := loss.NewTripletLoss(0.5, loss.BatchHard)
+	hard.Reduction = none
+	// Furthest positive 3, closest negative 2
+	if got := hard.Call(embeddings, ids, nil).Get(0, 0); math.Abs(got-1.5) > 1e-5 {
+		t.Errorf("Batch-hard loss of anchor 0 should be 1.5, got %.4f", got)
+	}
+	semiHard := loss.NewTripletLoss(0.5, loss.SemiHard)
+	semiHard.Reduction = none
+	// Positive 1 pairs with negative 2, giving max(0, 1 - 2 + 0.5) = 0,
+	// positive 3 with negative 4, giving max(0, 3 - 4 + 0.5) = 0
+	if got := semiHard.Call(embeddings, ids, nil).Get(0, 0); got != 0 {
+		t.Errorf("Semi-hard loss of anchor 0 should be 0, got %.4f", got)
+	}
+}
+
+func TestNTXent_Values(t *testing.T) {
+	// Two views of two samples, each view's positive is the other view
+	embeddings := nn.NewMatrixFromArray([][]float32{{1, 0}, {0, 2}, {1, 1}, {0, -1}})
+	ids := nn.NewMatrixFromArray([][]float32{{0}, {1}, {0}, {1}})
+	ntXent := loss.NewNTXentLoss(1)
+	ntXent.Reduction = nn.ReduceNone
+	s := 1 / math.Sqrt(2)
+	// Anchor 0 has similarities 0, s and 0 with the others, its positive is sample 2
+	want := -math.Log(math.Exp(s) / (1 + math.Exp(s) + 1))
+	if got := ntXent.Call(embeddings, ids, nil).Get(0, 0); math.Abs(got-want) > 1e-5 {
+		t.Errorf("NT-Xent loss of anchor 0 should be %.4f, got %.4f", want, got)
+	}
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	math "github.com/chewxy/math32"
+	"math/rand"
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"nn-go/nn/loss"
+	"nn-go/nn/regularizers"
+	"testing"
+)
+
+func TestEWC_Penalty(t *testing.T) {
+	// One weight w on x = 1 with target 0, the loss (w - 0)^2 has gradient 2w
+	model := nn.NewModel(nn.Shape{1}, &loss.MeanSquaredError{}, &fixedLr{0.1})
+	dense := layers.NewDenseLayer(1, false, activations.Linear, initializers.NewConstInitializer(1.5), initializers.Zero{})
+	model.AddLayer(dense)
+	model.Init()
+	data := nn.DataSet{Instances: nn.NewMatrixFromArray([][]float32{{1}, {1}}), Labels: nn.NewMatrix(2, 1)}
+	ewc := nn.NewEWC(2)
+	ewc.Consolidate(model, data)
+	w := dense.Params()[0]
+	if w.Grad.Get(0, 0) != 0 {
+		t.Errorf("Consolidating should leave the gradients cleared, got %v", w.Grad.Get(0, 0))
+	}
+	// Fisher (2 * 1.5)^2 = 9, penalty 2/2 * 9 * (2.5 - 1.5)^2
+	w.Value.Set(0, 0, 2.5)
+	if got := ewc.Penalty(model.Params()); math.Abs(got-9) > 1e-5 {
+		t.Errorf("Penalty should be 9, got %v", got)
+	}
+	ewc.AddGradients(model.Params())
+	if got := w.Grad.Get(0, 0); math.Abs(got-18) > 1e-5 {
+		t.Errorf("Penalty gradient should be 2 * 9 * 1 = 18, got %v", got)
+	}
+	// A second task anchored at 2.5 with Fisher 25 adds to the first
+	w.ZeroGrad()
+	ewc.Consolidate(model, data)
+	w.Value.Set(0, 0, 3.5)
+	if got := ewc.Penalty(model.Params()); ewc.Tasks() != 2 || math.Abs(got-(9*4+25)) > 1e-4 {
+		t.Errorf("Penalty over 2 tasks should be 61, got %v", got)
+	}
+}
+
+func TestEWC_IgnoresRegularizers(t *testing.T) {
+	// The task only uses the first input, so the weight of the second has no Fisher information even
+	// though the L2 penalty on it has gradient 2 * 0.5 * 1
+	model := nn.NewModel(nn.Shape{2}, &loss.MeanSquaredError{}, &fixedLr{0.1})
+	dense := layers.NewDenseLayer(1, false, activations.Linear, initializers.NewConstInitializer(1), initializers.Zero{}).
+		WithKernelRegularizer(regularizers.NewL2Regularizer(0.5))
+	model.AddLayer(dense)
+	model.Init()
+	data := nn.DataSet{Instances: nn.NewMatrixFromArray([][]float32{{1, 0}, {-1, 0}}), Labels: nn.NewMatrix(2, 1)}
+	ewc := nn.NewEWC(2)
+	ewc.Consolidate(model, data)
+	// Moving only the unused weight costs nothing
+	w := dense.Params()[0]
+	w.Value.Set(1, 0, 3)
+	if got := ewc.Penalty(model.Params()); got != 0 {
+		t.Errorf("The unused weight should have no Fisher information, penalty %v", got)
+	}
+	// The regularizer still applies in training afterwards
+	w.Value.Set(1, 0, 1)
+	dense.Backward(nn.NewMatrix(1, 2), nn.NewMatrix(1, 1))
+	if got := w.Grad.Get(1, 0); math.Abs(got-1) > 1e-6 {
+		t.Errorf("Consolidate should turn the penalty gradients back on, got %v", got)
+	}
+}
+
+// task a linear regression y = a*x0 + b*x1. It has its own source so it doesn't change the values
+// rng gives other tests
+func task(n int, a float32, b float32) nn.DataSet {
+	source := rand.New(rand.NewSource(7))
+	x, y := nn.NewMatrix(n, 2), nn.NewMatrix(n, 1)
+	for i := 0; i < n; i++ {
+		x.Set(i, 0, source.Float32()*2-1)
+		x.Set(i, 1, source.Float32()*2-1)
+		y.Set(i, 0, a*x.Get(i, 0)+b*x.Get(i, 1))
+	}
+	return nn.DataSet{Instances: x, Labels: y}
+}
+
+func TestEWC_Forgetting(t *testing.T) {
+	first, second := task(32, 2, -3), task(32, -1, -3)
+	// The first task only uses the first input when its second is 0
+	for i := 0; i < 32; i++ {
+		first.Instances.Set(i, 1, 0)
+	}
+	firstLoss := func(ewc *nn.EWC) float32 {
+		model := nn.NewModel(nn.Shape{2}, &loss.MeanSquaredError{}, &fixedLr{0.1})
+		model.AddLayer(layers.NewDenseLayer(1, false, activations.Linear, initializers.Zero{}, initializers.Zero{}))
+		model.Init()
+		model.Train(nn.NewTrainArgs(&nn.TrainTestSet{Train: first, Test: first}, nil, 30, 8, false))
+		args := nn.NewTrainArgs(&nn.TrainTestSet{Train: second, Test: second}, nil, 30, 8, false)
+		if ewc != nil {
+			ewc.Consolidate(model, first)
+			args.WithEWC(ewc)
+		}
+		model.Train(args)
+		return model.Loss(model.Predict(first.Instances), first.Labels).Mean()
+	}
+	forgot, remembered := firstLoss(nil), firstLoss(nn.NewEWC(10))
+	if remembered >= forgot/2 {
+		t.Errorf("EWC should forget less of the first task, loss %v without and %v with", forgot, remembered)
+	}
+}
@@ -0,0 +1,92 @@
+package test
+
+import (
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"nn-go/nn/loss"
+	"testing"
+)
+
+// zeroGrads whether every param of layer has no gradient
+func zeroGrads(layer nn.Layer) bool {
+	for _, p := range layer.(nn.ParamLayer).Params() {
+		if !p.Grad.Eq(nn.NewMatrixLike(p.Grad)).All() {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSetTrainable_Backward(t *testing.T) {
+	frozenLayers := []nn.Layer{
+		layers.NewDenseLayer(3, true, activations.Tanh, initializers.Glorot{}, initializers.Zero{}),
+		layers.NewTransformerEncoderBlock(2, 2, 4, false, activations.ReLU, initializers.Glorot{}),
+	}
+	for _, layer := range frozenLayers {
+		layer.Init(nn.Shape{2, 4})
+		input, grads := randomMatrix(2, 8), randomMatrix(2, layer.Outputs()*2)
+		trainable := layer.Backward(input, grads)
+		layer.(nn.TrainableLayer).SetTrainable(false)
+		for _, p := range layer.(nn.ParamLayer).Params() {
+			p.ZeroGrad()
+		}
+		frozen := layer.Backward(input, grads)
+		if !frozen.Eq(trainable).All() {
+			t.Errorf("%T frozen should still return the input gradient %v, got %v", layer, trainable, frozen)
+		}
+		if !zeroGrads(layer) {
+			t.Errorf("%T frozen should not accumulate param gradients", layer)
+		}
+	}
+}
+
+// backwardCounter counts its Backward calls
+type backwardCounter struct {
+	*layers.Activation
+	calls int
+}
+
+func (b *backwardCounter) Backward(input *nn.Matrix, grads *nn.Matrix) *nn.Matrix {
+	b.calls++
+	return b.Activation.Backward(input, grads)
+}
+
+func TestModel_Freeze(t *testing.T) {
+	counter := &backwardCounter{Activation: layers.NewActivationLayer(activations.Linear)}
+	base := layers.NewDenseLayer(4, true, activations.Tanh, initializers.Glorot{}, initializers.Zero{})
+	model := nn.NewModel(nn.Shape{2}, &loss.CategoricalCrossEntropy{}, &fixedLr{0.5})
+	model.
+		AddLayer(counter).
+		AddLayer(base).
+		AddLayer(layers.NewDenseLayer(3, true, activations.Linear, initializers.Glorot{}, initializers.Zero{})).
+		AddLayer(layers.NewSoftmaxLayer(3))
+	model.Init()
+	model.Freeze(0, 2)
+	if base.Trainable() || len(model.Params()) != 2 {
+		t.Errorf("Only the head's 2 params should be trainable, got %d", len(model.Params()))
+	}
+	before := base.Params()[0].Value.Copy()
+	data := nn.TrainTestSet{Train: blobs(9), Test: blobs(3)}
+	model.Train(nn.NewTrainArgs(&data, nil, 2, 3, false))
+	if !base.Params()[0].Value.Eq(before).All() {
+		t.Errorf("Frozen base should not change")
+	}
+	if counter.calls != 0 {
+		t.Errorf("Backward should stop before the frozen prefix, called %d times", counter.calls)
+	}
+	model.Unfreeze(1, 2)
+	model.Train(nn.NewTrainArgs(&data, nil, 1, 3, false))
+	if base.Params()[0].Value.Eq(before).All() {
+		t.Errorf("Unfrozen base should train")
+	}
+	if counter.calls != 0 {
+		t.Errorf("Nothing before the first trainable layer needs a gradient, called %d times", counter.calls)
+	}
+	model.Freeze(1, 2)
+	model.Unfreeze(0, 4)
+	if len(model.Params()) != 4 {
+		t.Errorf("Unfreezing all should make 4 params trainable, got %d", len(model.Params()))
+	}
+}
@@ -0,0 +1,155 @@
+package test
+
+import (
+	math "github.com/chewxy/math32"
+	"math/rand"
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"testing"
+)
+
+// fixedLr plain gradient descent with a constant learning rate
+type fixedLr struct {
+	lr float32
+}
+
+func (f *fixedLr) Step(params []*nn.Param) {
+	for _, p := range params {
+		p.Value.Sub(p.Grad.Copy().Multn(f.lr))
+	}
+}
+
+func (f *fixedLr) Lr() float32 {
+	return f.lr
+}
+
+func (f *fixedLr) SetLr(lr float32) {
+	f.lr = lr
+}
+
+// descend take a gradient descent step on the gradients accumulated by layer's Backward
+func descend(layer nn.Layer, lr float32) {
+	params := layer.(nn.ParamLayer).Params()
+	(&fixedLr{lr}).Step(params)
+	for _, p := range params {
+		p.ZeroGrad()
+	}
+}
+
+// rng a fixed seed keeps the gradient checks away from flaky kinks like ReLU at 0
+var rng = rand.New(rand.NewSource(42))
+
+// seededGlorot Glorot uniform initialization drawing from its own source, unlike
+// initializers.Glorot which draws from the global one
+type seededGlorot struct {
+	rng *rand.Rand
+}
+
+func newSeededGlorot(seed int64) seededGlorot {
+	return seededGlorot{rand.New(rand.NewSource(seed))}
+}
+
+func (g seededGlorot) Call(layer nn.Layer) float32 {
+	limit := math.Sqrt(6 / float32(layer.Inputs()+layer.Outputs()))
+	return (g.rng.Float32()*2 - 1) * limit
+}
+
+func randomMatrix(rows int, cols int) *nn.Matrix {
+	return randomMatrixFrom(rng, rows, cols)
+}
+
+// randomMatrixFrom values uniform in [-1, 1) drawn from source
+func randomMatrixFrom(source *rand.Rand, rows int, cols int) *nn.Matrix {
+	m := nn.NewMatrix(rows, cols)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			m.Set(i, j, source.Float32()*2-1)
+		}
+	}
+	return m
+}
+
+// weightedSum the scalar sum(output * weights) used as the loss in gradient checks
+func weightedSum(output *nn.Matrix, weights *nn.Matrix) float32 {
+	return output.Copy().Mult(weights).Sum()
+}
+
+// checkInputGradients compare the gradient returned by layer.Backward with central differences
+// of sum(layer.Forward(input) * upstream) with respect to every input
+func checkInputGradients(t *testing.T, layer nn.Layer, input *nn.Matrix) {
+	t.Helper()
+	upstream := randomMatrix(input.Rows(), layer.Forward(input).Cols())
+	analytic := layer.Backward(input, upstream)
+	const eps = 1e-3
+	for i := 0; i < input.Rows(); i++ {
+		for j := 0; j < input.Cols(); j++ {
+			orig := input.Get(i, j)
+			input.Set(i, j, orig+eps)
+			plus := weightedSum(layer.Forward(input), upstream)
+			input.Set(i, j, orig-eps)
+			minus := weightedSum(layer.Forward(input), upstream)
+			input.Set(i, j, orig)
+			numeric := (plus - minus) / (2 * eps)
+			if diff := math.Abs(numeric - analytic.Get(i, j)); diff > 1e-2*math.Max(1, math.Abs(numeric)) {
+				t.Fatalf("Input gradient at (%d, %d) is %.5f, numerically %.5f", i, j, analytic.Get(i, j), numeric)
+			}
+		}
+	}
+}
+
+// checkParamGradients compare the parameter gradients accumulated by layer.Backward with central
+// differences of sum(layer.Forward(input) * upstream) with respect to every parameter value
+func checkParamGradients(t *testing.T, name string, layer nn.Layer, input *nn.Matrix) {
+	t.Helper()
+	upstream := randomMatrix(input.Rows(), layer.Forward(input).Cols())
+	params := layer.(nn.ParamLayer).Params()
+	for _, p := range params {
+		p.ZeroGrad()
+	}
+	layer.Backward(input, upstream)
+	const eps = 1e-3
+	for _, p := range params {
+		for i := 0; i < p.Value.Rows(); i++ {
+			for j := 0; j < p.Value.Cols(); j++ {
+				orig := p.Value.Get(i, j)
+				p.Value.Set(i, j, orig+eps)
+				plus := weightedSum(layer.Forward(input), upstream)
+				p.Value.Set(i, j, orig-eps)
+				minus := weightedSum(layer.Forward(input), upstream)
+				p.Value.Set(i, j, orig)
+				numeric := (plus - minus) / (2 * eps)
+				if diff := math.Abs(numeric - p.Grad.Get(i, j)); diff > 1e-2*math.Max(1, math.Abs(numeric)) {
+					t.Fatalf("%s %s gradient at (%d, %d) is %.5f, numerically %.5f", name, p.Name, i, j, p.Grad.Get(i, j), numeric)
+				}
+			}
+		}
+	}
+}
+
+func TestParams_Gradients(t *testing.T) {
+	const steps, features = 3, 4
+	sequence := nn.Shape{steps, features}
+	recurrent := recurrentLayers(true, false)
+	// In a fixed order so the checks always draw the same random values
+	names := []string{"Dense", "LayerNormalization", "MultiHeadAttention", "Transformer", "SimpleRNN", "LSTM", "GRU"}
+	paramLayers := []nn.Layer{
+		layers.NewDenseLayer(3, true, activations.Tanh, initializers.Glorot{}, initializers.NewConstInitializer(0.1)),
+		layers.NewLayerNormalizationLayer(1e-6),
+		layers.NewMultiHeadAttentionLayer(2, 3, true, initializers.Glorot{}, initializers.NewConstInitializer(0.1)),
+		layers.NewTransformerEncoderBlock(2, 2, 6, false, activations.GELU, initializers.Glorot{}),
+		recurrent["SimpleRNN"], recurrent["LSTM"], recurrent["GRU"],
+	}
+	for i, l := range paramLayers {
+		name := names[i]
+		l.Init(sequence)
+		if norm, ok := l.(*layers.LayerNormalization); ok {
+			// Move gamma and beta away from 1 and 0 so their gradients are exercised
+			for _, p := range norm.Params() {
+				p.Value.Add(randomMatrix(1, features).Multn(0.5))
+			}
+		}
+		checkParamGradients(t, name, l, randomMatrix(2, steps*features))
+	}
+}
@@ -0,0 +1,67 @@
+package test
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"nn-go/nn/loss"
+	"nn-go/nn/metrics"
+	"nn-go/nn/schedulers"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	observed := nn.NewMatrixFromArray([][]float32{{0.7, 0.3}, {0.6, 0.4}, {0.1, 0.9}, {0.2, 0.8}})
+	expected := nn.NewMatrixFromArray([][]float32{{1, 0}, {0, 1}, {0, 1}, {0, 1}})
+	if got := (metrics.CategoricalAccuracy{}).Call(observed, expected); got != 0.75 {
+		t.Errorf("Categorical accuracy should be 0.75, got %v", got)
+	}
+	if got := metrics.NewBinaryAccuracy(0.5).Call(observed, expected); got != 0.75 {
+		t.Errorf("Binary accuracy should be 0.75, got %v", got)
+	}
+	if got := (metrics.MeanAbsoluteError{}).Call(observed, expected); math.Abs(got-0.3) > 1e-6 {
+		t.Errorf("Mean absolute error should be 0.3, got %v", got)
+	}
+}
+
+func TestTrain_History(t *testing.T) {
+	model := nn.NewModel(nn.Shape{2}, &loss.CategoricalCrossEntropy{}, &fixedLr{0.4})
+	model.
+		AddLayer(layers.NewDenseLayer(3, true, activations.Linear, initializers.Zero{}, initializers.Zero{})).
+		AddLayer(layers.NewSoftmaxLayer(3))
+	model.Init()
+	data := nn.TrainTestSet{Train: blobs(9), Test: blobs(6)}
+	args := nn.NewTrainArgs(&data, nil, 4, 3, false).
+		WithScheduler(schedulers.NewStepDecay(2, 0.5, nn.PerEpoch)).
+		WithMetrics(metrics.CategoricalAccuracy{})
+	history := model.Train(args)
+
+	if history.Epochs() != 4 || len(history.ValidationLoss) != 4 || len(history.EpochDuration) != 4 {
+		t.Fatalf("Should record 4 epochs, got %d, %d and %d", history.Epochs(), len(history.ValidationLoss), len(history.EpochDuration))
+	}
+	if len(history.BatchLoss) != 12 {
+		t.Fatalf("Should record 12 batch losses, got %d", len(history.BatchLoss))
+	}
+	for epoch := 0; epoch < 4; epoch++ {
+		batches := history.BatchLoss[epoch*3 : epoch*3+3]
+		if mean := (batches[0] + batches[1] + batches[2]) / 3; math.Abs(mean-history.TrainLoss[epoch]) > 1e-6 {
+			t.Errorf("Epoch %d train loss should be the mean batch loss %v, got %v", epoch, mean, history.TrainLoss[epoch])
+		}
+	}
+	// The last validation loss is of the final weights, and differs from the training loss
+	want := model.Loss(model.Predict(data.Test.Instances), data.Test.Labels).Mean()
+	if history.ValidationLoss[3] != want || history.ValidationLoss[3] == history.TrainLoss[3] {
+		t.Errorf("Final validation loss should be %v, got %v", want, history.ValidationLoss[3])
+	}
+	if lrs := history.Lr; lrs[0] != 0.4 || lrs[1] != 0.4 || lrs[2] != 0.2 || lrs[3] != 0.2 {
+		t.Errorf("Learning rate should halve after 2 epochs, got %v", lrs)
+	}
+	if len(history.Metrics["accuracy"]) != 4 || len(history.Metrics["val_accuracy"]) != 4 {
+		t.Fatalf("Should record accuracy on the training and test data, got %v", history.Metrics)
+	}
+	if accuracy := history.Metrics["val_accuracy"][3]; accuracy < 0.99 {
+		t.Errorf("The blobs should be separated, validation accuracy %v", accuracy)
+	}
+}
@@ -0,0 +1,153 @@
+package test
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"nn-go/nn/loss"
+	"testing"
+)
+
+// probabilities a matrix of values in [0.05, 0.95]
+func probabilities(rows int, cols int) *nn.Matrix {
+	return randomMatrix(rows, cols).Multn(0.45).Addn(0.5)
+}
+
+func TestLosses_Gradients(t *testing.T) {
+	for _, r := range []nn.Reduction{nn.ReduceMean, nn.ReduceSum, nn.ReduceNone} {
+		huber := loss.NewHuberLoss(0.5)
+		huber.Reduction = r
+		smoothing := loss.NewLabelSmoothingCrossEntropy(0.1)
+		smoothing.Reduction = r
+		focal := loss.NewFocalLoss(2, 0.25)
+		focal.Reduction = r
+		binary := oneHot(4, 3)
+		counts := nn.NewMatrixFromArray([][]float32{{0, 1, 3}, {2, 0, 1}, {1, 1, 0}, {4, 2, 1}})
+		sparse := nn.NewMatrixFromArray([][]float32{{2}, {0}, {1}, {2}})
+		cases := []struct {
+			name     string
+			loss     nn.Loss
+			observed *nn.Matrix
+			expected *nn.Matrix
+		}{
+			{"MeanSquaredError", &loss.MeanSquaredError{Reduction: r}, randomMatrix(4, 3), randomMatrix(4, 3)},
+			{"MeanAbsoluteError", &loss.MeanAbsoluteError{Reduction: r}, randomMatrix(4, 3), randomMatrix(4, 3)},
+			{"Huber", huber, randomMatrix(4, 3).Multn(2), randomMatrix(4, 3)},
+			{"LogCosh", &loss.LogCosh{Reduction: r}, randomMatrix(4, 3).Multn(3), randomMatrix(4, 3)},
+			{"BinaryCrossEntropy", &loss.BinaryCrossEntropy{Reduction: r}, probabilities(4, 3), binary},
+			{"BinaryCrossEntropyWithLogits", &loss.BinaryCrossEntropyWithLogits{Reduction: r}, randomMatrix(4, 3).Multn(3), binary},
+			{"CategoricalCrossEntropy", &loss.CategoricalCrossEntropy{Reduction: r}, probabilities(4, 3), binary},
+			{"LabelSmoothingCrossEntropy", smoothing, probabilities(4, 3), binary},
+			{"LabelSmoothingSoftmaxCrossEntropy", smoothing.FuseSoftmax(), randomMatrix(4, 3).Multn(3), binary},
+			{"Focal", focal, probabilities(4, 3), binary},
+			{"SparseCategoricalCrossEntropy", &loss.SparseCategoricalCrossEntropy{Reduction: r}, probabilities(4, 3), sparse},
+			{"SparseSoftmaxCrossEntropy", &loss.SparseSoftmaxCrossEntropy{Reduction: r}, randomMatrix(4, 3).Multn(3), sparse},
+			{"KLDivergence", &loss.KLDivergence{Reduction: r}, probabilities(4, 3), randomMatrix(4, 3).Softmax()},
+			{"Hinge", &loss.Hinge{Reduction: r}, randomMatrix(4, 3).Multn(0.5), binary},
+			{"SquaredHinge", &loss.SquaredHinge{Reduction: r}, randomMatrix(4, 3).Multn(0.5), binary},
+			{"Poisson", &loss.Poisson{Reduction: r}, probabilities(4, 3).Multn(3), counts},
+			{"CosineSimilarity", &loss.CosineSimilarity{Reduction: r}, randomMatrix(4, 3), randomMatrix(4, 3)},
+		}
+		for _, c := range cases {
+			checkLossGradients(t, c.name, c.loss, c.observed, c.expected)
+		}
+	}
+}
+
+func TestLosses_Values(t *testing.T) {
+	observed := nn.NewMatrixFromArray([][]float32{{1, 2}, {0, 0}})
+	expected := nn.NewMatrixFromArray([][]float32{{0, 4}, {0, 1}})
+	cases := []struct {
+		name string
+		loss nn.Loss
+		want float32
+	}{
+		{"MeanSquaredError", &loss.MeanSquaredError{}, (2.5 + 0.5) / 2},
+		{"MeanAbsoluteError", &loss.MeanAbsoluteError{}, (1.5 + 0.5) / 2},
+		{"Huber", loss.NewHuberLoss(1), (0.5 + 1.5 + 0.5) / 4},
+		{"Hinge", &loss.Hinge{}, (2 + 0 + 1 + 1) / 4},
+		{"CosineSimilarity", &loss.CosineSimilarity{}, (-8 / (math.Sqrt(5) * 4)) / 2},
+	}
+	for _, c := range cases {
+		if got := c.loss.Call(observed, expected, nil); got.Rows() != 1 || math.Abs(got.Get(0, 0)-c.want) > 1e-4 {
+			t.Errorf("%s = %.4f, want %.4f", c.name, got.Get(0, 0), c.want)
+		}
+	}
+}
+
+func TestLosses_Reduction(t *testing.T) {
+	observed := nn.NewMatrixFromArray([][]float32{{1}, {3}})
+	expected := nn.NewMatrixFromArray([][]float32{{0}, {0}})
+	mean := (&loss.MeanSquaredError{}).Call(observed, expected, nil)
+	sum := (&loss.MeanSquaredError{Reduction: nn.ReduceSum}).Call(observed, expected, nil)
+	none := (&loss.MeanSquaredError{Reduction: nn.ReduceNone}).Call(observed, expected, nil)
+	if mean.Rows() != 1 || mean.Get(0, 0) != 5 {
+		t.Errorf("Mean reduction should be 5, got %v", mean)
+	}
+	if sum.Rows() != 1 || sum.Get(0, 0) != 10 {
+		t.Errorf("Sum reduction should be 10, got %v", sum)
+	}
+	if none.Rows() != 2 || none.Get(0, 0) != 1 || none.Get(1, 0) != 9 {
+		t.Errorf("No reduction should give each sample's loss, got %v", none)
+	}
+	meanGrads := (&loss.MeanSquaredError{}).Gradient(observed, expected, nil)
+	sumGrads := (&loss.MeanSquaredError{Reduction: nn.ReduceSum}).Gradient(observed, expected, nil)
+	if meanGrads.Get(1, 0) != 3 || sumGrads.Get(1, 0) != 6 {
+		t.Errorf("Gradients should be scaled by the reduction, got %.2f and %.2f", meanGrads.Get(1, 0), sumGrads.Get(1, 0))
+	}
+}
+
+func TestTrain_ReduceNoneLoss(t *testing.T) {
+	// ReduceNone backpropagates the summed loss, so it should train and report the same as ReduceSum
+	train := func(r nn.Reduction) nn.History {
+		model := nn.NewModel(nn.Shape{2}, &loss.MeanSquaredError{Reduction: r}, &fixedLr{0.01})
+		model.AddLayer(layers.NewDenseLayer(1, true, activations.Linear, initializers.Zero{}, initializers.Zero{}))
+		model.Init()
+		data := nn.TrainTestSet{Train: regression(16), Test: regression(8)}
+		return model.Train(nn.NewTrainArgs(&data, nil, 3, 8, false))
+	}
+	sum, none := train(nn.ReduceSum), train(nn.ReduceNone)
+	for i := range sum.TrainLoss {
+		if math.Abs(sum.TrainLoss[i]-none.TrainLoss[i]) > 1e-5 || math.Abs(sum.ValidationLoss[i]-none.ValidationLoss[i]) > 1e-5 {
+			t.Errorf("Epoch %d losses with ReduceNone (%.5f, %.5f) should be the summed ones (%.5f, %.5f)",
+				i, none.TrainLoss[i], none.ValidationLoss[i], sum.TrainLoss[i], sum.ValidationLoss[i])
+		}
+	}
+}
+
+func TestLosses_LabelSmoothing(t *testing.T) {
+	probs := probabilities(4, 3).Softmax()
+	labels := oneHot(4, 3)
+	cce := (&loss.CategoricalCrossEntropy{}).Call(probs, labels, nil).Get(0, 0)
+	if got := loss.NewLabelSmoothingCrossEntropy(0).Call(probs, labels, nil).Get(0, 0); math.Abs(got-cce) > 1e-6 {
+		t.Errorf("No smoothing should match CategoricalCrossEntropy %.4f, got %.4f", cce, got)
+	}
+	// Smoothing 0.3 over 3 classes gives targets of 0.8 and 0.1
+	soft := labels.Copy().Multn(0.7).Addn(0.1)
+	want := (&loss.CategoricalCrossEntropy{}).Call(probs, soft, nil).Get(0, 0)
+	if got := loss.NewLabelSmoothingCrossEntropy(0.3).Call(probs, labels, nil).Get(0, 0); math.Abs(got-want) > 1e-6 {
+		t.Errorf("Smoothed loss should be %.4f, got %.4f", want, got)
+	}
+}
+
+func TestLosses_Focal(t *testing.T) {
+	probs := probabilities(4, 3).Softmax()
+	labels := oneHot(4, 3)
+	cce := (&loss.CategoricalCrossEntropy{}).Call(probs, labels, nil).Get(0, 0)
+	if got := loss.NewFocalLoss(0, 1).Call(probs, labels, nil).Get(0, 0); math.Abs(got-cce) > 1e-6 {
+		t.Errorf("Focal loss with gamma 0 should match CategoricalCrossEntropy %.4f, got %.4f", cce, got)
+	}
+	// A confident correct prediction is down-weighted far more than an uncertain one
+	focal := loss.NewFocalLoss(2, 1)
+	easy := nn.NewMatrixFromArray([][]float32{{0.9, 0.1}})
+	hard := nn.NewMatrixFromArray([][]float32{{0.4, 0.6}})
+	label := nn.NewMatrixFromArray([][]float32{{1, 0}})
+	if want := 0.01 * -math.Log(0.9); math.Abs(focal.Call(easy, label, nil).Get(0, 0)-want) > 1e-6 {
+		t.Errorf("Focal loss of an easy sample should be %.5f, got %.5f", want, focal.Call(easy, label, nil).Get(0, 0))
+	}
+	if want := 0.36 * -math.Log(0.4); math.Abs(focal.Call(hard, label, nil).Get(0, 0)-want) > 1e-6 {
+		t.Errorf("Focal loss of a hard sample should be %.5f, got %.5f", want, focal.Call(hard, label, nil).Get(0, 0))
+	}
+}
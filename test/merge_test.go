@@ -0,0 +1,144 @@
+package test
+
+import (
+	math "github.com/chewxy/math32"
+	"math/rand"
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"nn-go/nn/loss"
+	"testing"
+)
+
+// nearTie whether another input is within tolerance of input k at i,j, where the maximum can switch
+// inputs between the two sides of a central difference
+func nearTie(inputs []*nn.Matrix, k int, i int, j int, tolerance float32) bool {
+	for other, input := range inputs {
+		if other != k && math.Abs(input.Get(i, j)-inputs[k].Get(i, j)) <= tolerance {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMerge_InputGradients(t *testing.T) {
+	for seed, test := range []struct {
+		name  string
+		layer nn.MergeLayer
+		count int
+	}{
+		{"Add", layers.NewAddLayer(), 3},
+		{"Subtract", layers.NewSubtractLayer(), 2},
+		{"Multiply", layers.NewMultiplyLayer(), 3},
+		{"Average", layers.NewAverageLayer(), 3},
+		{"Maximum", layers.NewMaximumLayer(), 3},
+		{"Concatenate", layers.NewConcatenateLayer(), 3},
+	} {
+		// Its own source, so the inputs don't depend on what other tests took from rng
+		source := rand.New(rand.NewSource(int64(seed)))
+		sizes := make([]nn.Shape, test.count)
+		inputs := make([]*nn.Matrix, test.count)
+		for i := range inputs {
+			sizes[i] = nn.Shape{4}
+			inputs[i] = randomMatrixFrom(source, 2, 4)
+		}
+		outputs := test.layer.Init(sizes).Size()
+		upstream := randomMatrixFrom(source, 2, outputs)
+		grads := test.layer.Backward(inputs, upstream)
+		if len(grads) != test.count {
+			t.Fatalf("%s returned %d gradients for %d inputs", test.name, len(grads), test.count)
+		}
+		const eps = 1e-3
+		for k, input := range inputs {
+			for i := 0; i < input.Rows(); i++ {
+				for j := 0; j < input.Cols(); j++ {
+					if test.name == "Maximum" && nearTie(inputs, k, i, j, 2*eps) {
+						continue
+					}
+					orig := input.Get(i, j)
+					input.Set(i, j, orig+eps)
+					plus := weightedSum(test.layer.Forward(inputs), upstream)
+					input.Set(i, j, orig-eps)
+					minus := weightedSum(test.layer.Forward(inputs), upstream)
+					input.Set(i, j, orig)
+					numeric := (plus - minus) / (2 * eps)
+					if math.Abs(numeric-grads[k].Get(i, j)) > 1e-2 {
+						t.Fatalf("%s gradient of input %d at (%d, %d) is %.4f, numerically %.4f",
+							test.name, k, i, j, grads[k].Get(i, j), numeric)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestResidual_InputGradients(t *testing.T) {
+	l := layers.NewResidualLayer(
+		layers.NewDenseLayer(3, true, activations.Tanh, newSeededGlorot(2), initializers.Zero{}),
+		layers.NewDenseLayer(4, true, activations.Linear, newSeededGlorot(3), initializers.Zero{}),
+	)
+	if output := l.Init(nn.Shape{4}); !output.Equal(nn.Shape{4}) {
+		t.Fatalf("Residual block should keep the input shape, got %v", output)
+	}
+	if len(l.Params()) != 4 {
+		t.Fatalf("Expected the kernel and bias of both Dense layers, got %d params", len(l.Params()))
+	}
+	checkInputGradients(t, l, randomMatrixFrom(rand.New(rand.NewSource(4)), 2, 4))
+}
+
+func TestBranches_InModel(t *testing.T) {
+	model := nn.NewModel(nn.Shape{2}, &loss.MeanSquaredError{}, &fixedLr{0.1})
+	model.AddLayer(layers.NewBranchesLayer(layers.NewConcatenateLayer(),
+		[]nn.Layer{layers.NewDenseLayer(2, true, activations.Tanh, newSeededGlorot(5), initializers.Zero{})},
+		[]nn.Layer{layers.NewDenseLayer(3, true, activations.Linear, newSeededGlorot(6), initializers.Zero{})},
+	))
+	model.AddLayer(layers.NewDenseLayer(1, true, activations.Linear, newSeededGlorot(7), initializers.Zero{}))
+	model.Init()
+	if output := model.Shapes()[1]; !output.Equal(nn.Shape{5}) {
+		t.Fatalf("Expected the branches to output shape (5), got %v", output)
+	}
+	if params := len(model.Params()); params != 6 {
+		t.Fatalf("Expected the model to train the params of both branches, got %d params", params)
+	}
+	data := nn.TrainTestSet{Train: regression(64), Test: regression(16)}
+	before := model.Loss(model.Predict(data.Test.Instances), data.Test.Labels).Mean()
+	model.Train(nn.NewTrainArgs(&data, nil, 20, 8, false))
+	if after := model.Loss(model.Predict(data.Test.Instances), data.Test.Labels).Mean(); after >= before/10 {
+		t.Errorf("Model with branches did not learn, test loss %.4f -> %.4f", before, after)
+	}
+}
+
+func TestConcatenate_Shape(t *testing.T) {
+	l := layers.NewConcatenateLayer()
+	if output := l.Init([]nn.Shape{{2}, {3}}); !output.Equal(nn.Shape{5}) {
+		t.Fatalf("Expected output shape (5), got %v", output)
+	}
+	a := nn.NewMatrixFromArray([][]float32{{1, 2}})
+	b := nn.NewMatrixFromArray([][]float32{{3, 4, 5}})
+	want := nn.NewMatrixFromArray([][]float32{{1, 2, 3, 4, 5}})
+	if !l.Forward([]*nn.Matrix{a, b}).Eq(want).All() {
+		t.Fatal("Concatenate did not join the inputs in order")
+	}
+	grads := l.Backward([]*nn.Matrix{a, b}, want)
+	if !grads[0].Eq(a).All() || !grads[1].Eq(b).All() {
+		t.Fatal("Concatenate did not split the gradient back to each input")
+	}
+}
+
+func TestConcatenate_Sequences(t *testing.T) {
+	l := layers.NewConcatenateLayer()
+	if output := l.Init([]nn.Shape{{2, 1}, {2, 2}}); !output.Equal(nn.Shape{2, 3}) {
+		t.Fatalf("Expected output shape (2, 3), got %v", output)
+	}
+	a := nn.NewMatrixFromArray([][]float32{{1, 4}})
+	b := nn.NewMatrixFromArray([][]float32{{2, 3, 5, 6}})
+	want := nn.NewMatrixFromArray([][]float32{{1, 2, 3, 4, 5, 6}})
+	if !l.Forward([]*nn.Matrix{a, b}).Eq(want).All() {
+		t.Fatal("Concatenate did not join each timestep's features")
+	}
+	grads := l.Backward([]*nn.Matrix{a, b}, want)
+	if !grads[0].Eq(a).All() || !grads[1].Eq(b).All() {
+		t.Fatal("Concatenate did not split the gradient back to each input")
+	}
+}
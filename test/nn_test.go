@@ -11,7 +11,7 @@ import (
 )
 
 func TestModel(t *testing.T) {
-	model := nn.NewModel(2, &loss.CategoricalCrossEntropy{}, optimisers.NewAdamOptimizer())
+	model := nn.NewModel(nn.Shape{2}, &loss.CategoricalCrossEntropy{}, optimisers.NewAdamOptimizer(0.001, 0.9, 0.999, 1e-8, false))
 	constInitializer := initializers.NewConstInitializer(0.01)
 	model.AddLayer(layers.NewDenseLayer(8, true, activations.ReLU, initializers.He{}, constInitializer))
 	model.AddLayer(layers.NewDenseLayer(4, true, activations.ReLU, initializers.He{}, constInitializer))
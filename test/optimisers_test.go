@@ -0,0 +1,295 @@
+package test
+
+import (
+	math "github.com/chewxy/math32"
+	"math/rand"
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"nn-go/nn/loss"
+	"nn-go/nn/optimisers"
+	"testing"
+)
+
+// scalarParam a 1x1 parameter with value v
+func scalarParam(v float32) *nn.Param {
+	return nn.NewParam("x", nn.NewMatrixFromArray([][]float32{{v}}))
+}
+
+// stepWith take a step of optimizer on p with gradient g, returning the new value
+func stepWith(optimizer nn.Optimizer, p *nn.Param, g float32) float32 {
+	p.Grad.Set(0, 0, g)
+	optimizer.Step([]*nn.Param{p})
+	return p.Value.Get(0, 0)
+}
+
+func TestAdam_BiasCorrection(t *testing.T) {
+	// With bias correction the first step is lr * g / |g| whatever the size of g
+	for _, g := range []float32{0.001, 1, 1000} {
+		p := scalarParam(1)
+		if got := stepWith(optimisers.NewAdamOptimizer(0.1, 0.9, 0.999, 1e-8, false), p, g); math.Abs(got-0.9) > 1e-5 {
+			t.Errorf("First step with gradient %v should move to 0.9, got %.6f", g, got)
+		}
+	}
+	// Second step, by hand
+	adam := optimisers.NewAdamOptimizer(0.1, 0.9, 0.999, 1e-8, false)
+	p := scalarParam(0)
+	stepWith(adam, p, 1)
+	m, v := float32(0.9*0.1+0.1*3), float32(0.999*0.001+0.001*9)
+	want := -0.1 - 0.1*(m/(1-0.81))/(math.Sqrt(v/(1-0.999*0.999))+1e-8)
+	if got := stepWith(adam, p, 3); math.Abs(got-want) > 1e-5 {
+		t.Errorf("Second step should move to %.6f, got %.6f", want, got)
+	}
+}
+
+func TestAdam_PerParamState(t *testing.T) {
+	adam := optimisers.NewAdamOptimizer(0.1, 0.9, 0.999, 1e-8, false)
+	a, b := scalarParam(0), scalarParam(0)
+	for i := 0; i < 5; i++ {
+		stepWith(adam, a, 10)
+	}
+	// b's first step is unaffected by a's history
+	if got := stepWith(adam, b, -1); math.Abs(got-0.1) > 1e-5 {
+		t.Errorf("A new parameter's first step should move to 0.1, got %.6f", got)
+	}
+}
+
+func TestAdam_Amsgrad(t *testing.T) {
+	adam := optimisers.NewAdamOptimizer(0.1, 0.9, 0.9, 1e-8, false)
+	amsgrad := optimisers.NewAdamOptimizer(0.1, 0.9, 0.9, 1e-8, true)
+	a, b := scalarParam(0), scalarParam(0)
+	// A large gradient then small ones, Adam's second moment decays and its steps grow again
+	for _, g := range []float32{100, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1} {
+		stepWith(adam, a, g)
+		stepWith(amsgrad, b, g)
+	}
+	before := [2]float32{a.Value.Get(0, 0), b.Value.Get(0, 0)}
+	adamStep := before[0] - stepWith(adam, a, 0.1)
+	amsgradStep := before[1] - stepWith(amsgrad, b, 0.1)
+	if amsgradStep >= adamStep {
+		t.Errorf("AMSGrad should take a smaller step than Adam after a large gradient, %.6f >= %.6f", amsgradStep, adamStep)
+	}
+}
+
+func TestAdam_Trains(t *testing.T) {
+	model := nn.NewModel(nn.Shape{2}, &loss.CategoricalCrossEntropy{}, optimisers.NewAdamOptimizer(0.01, 0.9, 0.999, 1e-8, false))
+	model.
+		AddLayer(layers.NewDenseLayer(8, true, activations.Tanh, initializers.Glorot{}, initializers.Zero{})).
+		AddLayer(layers.NewDenseLayer(3, true, activations.Linear, initializers.Glorot{}, initializers.Zero{})).
+		AddLayer(layers.NewSoftmaxLayer(3))
+	model.Init()
+	data := nn.TrainTestSet{Train: blobs(30), Test: blobs(9)}
+	before := model.Loss(model.Predict(data.Test.Instances), data.Test.Labels).Mean()
+	model.Train(nn.NewTrainArgs(&data, nil, 20, 10, true))
+	if after := model.Loss(model.Predict(data.Test.Instances), data.Test.Labels).Mean(); after >= before/2 {
+		t.Errorf("Test loss should fall by at least half, %.4f -> %.4f", before, after)
+	}
+}
+
+func TestSGD_Steps(t *testing.T) {
+	cases := []struct {
+		name string
+		sgd  *optimisers.SGD
+		want [3]float32
+	}{
+		// Gradients 1, 2 and 3 from a value of 0 with lr 0.1
+		{"Plain", optimisers.NewSGDOptimizer(0.1, 0, 0, 0, false), [3]float32{-0.1, -0.3, -0.6}},
+		// Velocities 1, 0.5 + 2 = 2.5, 1.25 + 3 = 4.25
+		{"Momentum", optimisers.NewSGDOptimizer(0.1, 0.5, 0, 0, false), [3]float32{-0.1, -0.35, -0.775}},
+		// Velocities 1, 0.5 + 1 = 1.5, 0.75 + 1.5 = 2.25
+		{"Dampening", optimisers.NewSGDOptimizer(0.1, 0.5, 0.5, 0, false), [3]float32{-0.1, -0.25, -0.475}},
+		// Steps of g + 0.5v: 1.5, 2 + 1.25, 3 + 2.125
+		{"Nesterov", optimisers.NewSGDOptimizer(0.1, 0.5, 0, 0, true), [3]float32{-0.15, -0.475, -0.9875}},
+	}
+	for _, c := range cases {
+		p := scalarParam(0)
+		for i, g := range []float32{1, 2, 3} {
+			if got := stepWith(c.sgd, p, g); math.Abs(got-c.want[i]) > 1e-6 {
+				t.Errorf("%s step %d should move to %.4f, got %.4f", c.name, i+1, c.want[i], got)
+			}
+		}
+	}
+	// Weight decay pulls the value towards 0 even without a gradient
+	p := scalarParam(2)
+	if got := stepWith(optimisers.NewSGDOptimizer(0.1, 0, 0, 0.5, false), p, 0); math.Abs(got-1.9) > 1e-6 {
+		t.Errorf("Weight decay should move 2 to 1.9, got %.4f", got)
+	}
+}
+
+// regression samples of y = 2a - 3b + 1
+func regression(n int) nn.DataSet {
+	// Its own source, so the data doesn't depend on how many values other tests took from rng
+	source := rand.New(rand.NewSource(int64(n)))
+	x, y := nn.NewMatrix(n, 2), nn.NewMatrix(n, 1)
+	for i := 0; i < n; i++ {
+		x.Set(i, 0, source.Float32()*2-1)
+		x.Set(i, 1, source.Float32()*2-1)
+		y.Set(i, 0, 2*x.Get(i, 0)-3*x.Get(i, 1)+1)
+	}
+	return nn.DataSet{Instances: x, Labels: y}
+}
+
+// regressionLoss the test loss of a linear model trained on regression with optimizer
+func regressionLoss(optimizer nn.Optimizer, epochs int) float32 {
+	model := nn.NewModel(nn.Shape{2}, &loss.MeanSquaredError{}, optimizer)
+	// Zero weights, rather than a random initialization, so every optimizer starts from the same place
+	model.AddLayer(layers.NewDenseLayer(1, true, activations.Linear, initializers.Zero{}, initializers.Zero{}))
+	model.Init()
+	data := nn.TrainTestSet{Train: regression(64), Test: regression(16)}
+	// Without shuffling, which draws from the unseeded global source, so every run is the same
+	model.Train(nn.NewTrainArgs(&data, nil, epochs, 8, false))
+	return model.Loss(model.Predict(data.Test.Instances), data.Test.Labels).Mean()
+}
+
+func TestSGD_Converges(t *testing.T) {
+	for name, sgd := range map[string]*optimisers.SGD{
+		"Plain":       optimisers.NewSGDOptimizer(0.1, 0, 0, 0, false),
+		"Momentum":    optimisers.NewSGDOptimizer(0.05, 0.9, 0, 0, false),
+		"Nesterov":    optimisers.NewSGDOptimizer(0.05, 0.9, 0, 0, true),
+		"WeightDecay": optimisers.NewSGDOptimizer(0.1, 0.5, 0, 1e-4, false),
+	} {
+		if got := regressionLoss(sgd, 30); got > 1e-3 {
+			t.Errorf("%s SGD should fit a linear regression, test loss %.5f", name, got)
+		}
+	}
+}
+
+func TestOptimisers_Steps(t *testing.T) {
+	cases := []struct {
+		name      string
+		optimizer nn.Optimizer
+		start     float32
+		grads     []float32
+		want      float32
+	}{
+		// v = 0.1, 0.1 / sqrt(0.1)
+		{"RMSprop", optimisers.NewRMSpropOptimizer(0.1, 0.9, 0, false), 0, []float32{1}, -0.316228},
+		// v - m^2 = 0.1 - 0.01, 0.1 / 0.3
+		{"CenteredRMSprop", optimisers.NewRMSpropOptimizer(0.1, 0.9, 0, true), 0, []float32{1}, -0.333333},
+		// 0.1 * 2 / sqrt(4), then 0.1 * 2 / sqrt(8)
+		{"Adagrad", optimisers.NewAdagradOptimizer(0.1, 0), 0, []float32{2, 2}, -0.1707107},
+		{"Adadelta", optimisers.NewAdadeltaOptimizer(1, 0.9, 1e-6), 0, []float32{1, 2}, -0.0072029},
+		// Decay 2 to 1.9, then Adam's first step of lr
+		{"AdamW", optimisers.NewAdamWOptimizer(0.1, 0.9, 0.999, 1e-8, 0.5, false), 2, []float32{1}, 1.8},
+		// 0.9 * 0.1 / (1 - 0.9^2) + 0.1 * 1 / (1 - 0.9)
+		{"Nadam", optimisers.NewNadamOptimizer(0.1, 0.9, 0.999, 1e-8), 0, []float32{1}, -0.1473684},
+		// (0.1 / (1 - 0.9)) * 0.1 / 1
+		{"Adamax", optimisers.NewAdamaxOptimizer(0.1, 0.9, 0.999, 0), 0, []float32{1}, -0.1},
+	}
+	for _, c := range cases {
+		p := scalarParam(c.start)
+		for _, g := range c.grads {
+			stepWith(c.optimizer, p, g)
+		}
+		if got := p.Value.Get(0, 0); math.Abs(got-c.want) > 1e-5 {
+			t.Errorf("%s should move to %.6f, got %.6f", c.name, c.want, got)
+		}
+	}
+}
+
+func TestRMSprop_CenteredConstantGradient(t *testing.T) {
+	// With a constant gradient the variance is 0, which rounding can take below 0
+	rmsprop := optimisers.NewRMSpropOptimizer(0.01, 0.9, 1e-8, true)
+	p := scalarParam(0)
+	for i := 0; i < 200; i++ {
+		if got := stepWith(rmsprop, p, 0.1); math.IsNaN(got) {
+			t.Fatalf("Centered RMSprop weight is NaN after %d steps", i+1)
+		}
+	}
+}
+
+func TestLAMB_TrustRatio(t *testing.T) {
+	lamb := optimisers.NewLAMBOptimizer(0.1, 0.9, 0.999, 0, 0)
+	p := nn.NewParam("x", nn.NewMatrixFromArray([][]float32{{3, 4}}))
+	p.Grad = nn.NewMatrixFromArray([][]float32{{1, 1}})
+	lamb.Step([]*nn.Param{p})
+	// The update is (1, 1), rescaled to 0.1 * 5 / sqrt(2)
+	want := 0.1 * 5 / math.Sqrt(2)
+	if math.Abs(p.Value.Get(0, 0)-(3-want)) > 1e-5 || math.Abs(p.Value.Get(0, 1)-(4-want)) > 1e-5 {
+		t.Errorf("LAMB should move to (%.4f, %.4f), got %v", 3-want, 4-want, p.Value)
+	}
+}
+
+func TestOptimisers_Converge(t *testing.T) {
+	cases := []struct {
+		name      string
+		optimizer nn.Optimizer
+		tolerance float32
+	}{
+		{"RMSprop", optimisers.NewRMSpropOptimizer(0.01, 0.9, 1e-8, false), 1e-2},
+		{"CenteredRMSprop", optimisers.NewRMSpropOptimizer(0.01, 0.9, 1e-8, true), 1e-2},
+		{"Adagrad", optimisers.NewAdagradOptimizer(0.5, 1e-10), 1e-2},
+		{"Adadelta", optimisers.NewAdadeltaOptimizer(50, 0.95, 1e-6), 1e-2},
+		{"AdamW", optimisers.NewAdamWOptimizer(0.05, 0.9, 0.999, 1e-8, 1e-4, false), 1e-2},
+		{"Nadam", optimisers.NewNadamOptimizer(0.05, 0.9, 0.999, 1e-8), 1e-2},
+		{"Adamax", optimisers.NewAdamaxOptimizer(0.05, 0.9, 0.999, 1e-8), 1e-2},
+		// Each LAMB step moves the weights by a fixed fraction of their size,
+		// so with a constant lr it keeps jittering around the solution
+		{"LAMB", optimisers.NewLAMBOptimizer(0.05, 0.9, 0.999, 1e-6, 0), 0.2},
+	}
+	for _, c := range cases {
+		if got := regressionLoss(c.optimizer, 60); got > c.tolerance {
+			t.Errorf("%s should fit a linear regression, test loss %.5f", c.name, got)
+		}
+	}
+}
+
+func TestLookahead(t *testing.T) {
+	p := scalarParam(0)
+	lookahead := optimisers.NewLookahead(&fixedLr{1}, 2, 0.5)
+	if got := stepWith(lookahead, p, 1); got != -1 {
+		t.Errorf("First fast step should move to -1, got %v", got)
+	}
+	// The fast weights reach -2, the slow weights move half way from 0 and the fast restart there
+	if got := stepWith(lookahead, p, 1); got != -1 {
+		t.Errorf("Slow step should move to -1, got %v", got)
+	}
+	if got := stepWith(lookahead, p, 1); got != -2 {
+		t.Errorf("Fast steps should restart from the slow weights, got %v", got)
+	}
+	lookahead.SetLr(0.1)
+	if lookahead.Lr() != 0.1 {
+		t.Errorf("Should set the wrapped optimizer's learning rate, got %v", lookahead.Lr())
+	}
+}
+
+func TestLBFGS_Rosenbrock(t *testing.T) {
+	// (1 - a)^2 + 100(b - a^2)^2 has a narrow curved valley to its minimum at (1, 1)
+	a, b := scalarParam(-1.5), scalarParam(2)
+	params := []*nn.Param{a, b}
+	closure := func() float32 {
+		x, y := a.Value.Get(0, 0), b.Value.Get(0, 0)
+		a.Grad.Set(0, 0, -2*(1-x)-400*x*(y-x*x))
+		b.Grad.Set(0, 0, 200*(y-x*x))
+		return (1-x)*(1-x) + 100*(y-x*x)*(y-x*x)
+	}
+	lbfgs := optimisers.NewLBFGSOptimizer(1, 10, 200, 1e-5)
+	if got := lbfgs.Minimize(params, closure); got > 1e-4 {
+		t.Errorf("L-BFGS should minimize the Rosenbrock function, got loss %v at (%v, %v)",
+			got, a.Value.Get(0, 0), b.Value.Get(0, 0))
+	}
+}
+
+func TestLBFGS_Model(t *testing.T) {
+	model := nn.NewModel(nn.Shape{2}, &loss.MeanSquaredError{}, nil)
+	model.AddLayer(layers.NewDenseLayer(1, true, activations.Linear, initializers.Zero{}, initializers.Zero{}))
+	model.Init()
+	data := regression(64)
+	flat := nn.FlattenValues(model.Params())
+	if len(flat) != 3 {
+		t.Fatalf("Dense(1) on 2 inputs should flatten to 3 values, got %v", flat)
+	}
+	// A quadratic, so a few iterations reach the exact weights 2, -3 and 1
+	lbfgs := optimisers.NewLBFGSOptimizer(1, 5, 20, 1e-6)
+	if got := lbfgs.Minimize(model.Params(), model.Closure(data)); got > 1e-6 {
+		t.Errorf("L-BFGS should fit a linear regression, got loss %v", got)
+	}
+	want := []float32{2, -3, 1}
+	for i, w := range nn.FlattenValues(model.Params()) {
+		if math.Abs(w-want[i]) > 1e-2 {
+			t.Errorf("Weights should be %v, got %v", want, nn.FlattenValues(model.Params()))
+			break
+		}
+	}
+}
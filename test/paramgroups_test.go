@@ -0,0 +1,66 @@
+package test
+
+import (
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"nn-go/nn/loss"
+	"testing"
+)
+
+func TestParamGroups_Step(t *testing.T) {
+	early, head := scalarParam(0), scalarParam(0)
+	groups := nn.NewParamGroups(&fixedLr{1}).AddGroup([]*nn.Param{early}, &fixedLr{0.1})
+	early.Grad.Set(0, 0, 1)
+	head.Grad.Set(0, 0, 1)
+	groups.Step([]*nn.Param{early, head})
+	if early.Value.Get(0, 0) != -0.1 || head.Value.Get(0, 0) != -1 {
+		t.Errorf("Grouped param should move by 0.1 and the rest by 1, got %v and %v", early.Value.Get(0, 0), head.Value.Get(0, 0))
+	}
+	// A scheduler halving the learning rate halves every group's
+	groups.SetLr(0.5)
+	groups.Step([]*nn.Param{early, head})
+	if early.Value.Get(0, 0) != -0.15 || head.Value.Get(0, 0) != -1.5 || groups.Lr() != 0.5 {
+		t.Errorf("Halved learning rates should move by 0.05 and 0.5, got %v and %v", early.Value.Get(0, 0), head.Value.Get(0, 0))
+	}
+}
+
+func TestModel_LayerParams(t *testing.T) {
+	model := nn.NewModel(nn.Shape{2}, &loss.CategoricalCrossEntropy{}, &fixedLr{0.1})
+	model.
+		AddNamedLayer("body", layers.NewDenseLayer(4, true, activations.Tanh, initializers.Glorot{}, initializers.Zero{})).
+		AddLayer(layers.NewLayerNormalizationLayer(1e-6)).
+		AddNamedLayer("head", layers.NewDenseLayer(3, true, activations.Linear, initializers.Glorot{}, initializers.Zero{})).
+		AddLayer(layers.NewSoftmaxLayer(3))
+	model.Init()
+	if model.LayerIndex("head") != 2 || model.LayerIndex("missing") != -1 {
+		t.Errorf("Head should be layer 2, got %d", model.LayerIndex("head"))
+	}
+	head := model.NamedLayerParams("head")
+	if byIndex := model.LayerParams(-2); len(head) != 2 || byIndex[0] != head[0] || byIndex[1] != head[1] {
+		t.Errorf("Head params by name and index should match, got %v and %v", head, byIndex)
+	}
+	if params := model.LayerParams(3); len(params) != 0 {
+		t.Errorf("Softmax should have no params, got %v", params)
+	}
+
+	decay, exempt := nn.SplitDecay(model.Params())
+	var decayNames, exemptNames []string
+	for _, p := range decay {
+		decayNames = append(decayNames, p.Name)
+	}
+	for _, p := range exempt {
+		exemptNames = append(exemptNames, p.Name)
+	}
+	if len(decay) != 2 || decayNames[0] != "kernel" || decayNames[1] != "kernel" {
+		t.Errorf("Only the kernels should be decayed, got %v", decayNames)
+	}
+	if len(exempt) != 4 {
+		t.Errorf("The biases, gamma and beta should be exempt from decay, got %v", exemptNames)
+	}
+
+	if !nn.DecayExempt(nn.NewParam("attention/query_bias", nn.NewMatrix(1, 1))) {
+		t.Errorf("Prefixed attention biases should be exempt from decay")
+	}
+}
@@ -0,0 +1,145 @@
+package test
+
+import (
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"testing"
+)
+
+const (
+	seqSteps    = 4
+	seqFeatures = 3
+)
+
+type recurrentLayer interface {
+	nn.Layer
+	SetInitialState(states ...*nn.Matrix)
+	SetTruncation(steps int)
+	InitialStateGradients() []*nn.Matrix
+}
+
+func recurrentLayers(returnSequences bool, returnState bool) map[string]recurrentLayer {
+	ortho := initializers.NewOrthogonalInitializer(1)
+	bias := initializers.NewConstInitializer(0.1)
+	return map[string]recurrentLayer{
+		"SimpleRNN": layers.NewSimpleRNNLayer(5, returnSequences, returnState, true, activations.Tanh, initializers.Glorot{}, ortho, bias),
+		"LSTM":      layers.NewLSTMLayer(5, returnSequences, returnState, true, initializers.Glorot{}, ortho, bias),
+		"GRU":       layers.NewGRULayer(5, returnSequences, returnState, true, initializers.Glorot{}, ortho, bias),
+	}
+}
+
+func TestRecurrent_OutputShape(t *testing.T) {
+	states := map[string]int{"SimpleRNN": 1, "LSTM": 2, "GRU": 1}
+	for _, seq := range []bool{false, true} {
+		for _, state := range []bool{false, true} {
+			for name, l := range recurrentLayers(seq, state) {
+				outputs := l.Init(nn.Shape{seqSteps, seqFeatures}).Size()
+				want := 5
+				if seq {
+					want *= seqSteps
+				}
+				if state {
+					want += 5 * states[name]
+				}
+				out := l.Forward(randomMatrix(2, seqSteps*seqFeatures))
+				if outputs != want || out.Cols() != want || out.Rows() != 2 {
+					t.Errorf("%s sequences=%v state=%v: got %d outputs and a (%d, %d) matrix, want %d",
+						name, seq, state, outputs, out.Rows(), out.Cols(), want)
+				}
+			}
+		}
+	}
+}
+
+func TestRecurrent_InputGradients(t *testing.T) {
+	for _, seq := range []bool{false, true} {
+		for name, l := range recurrentLayers(seq, true) {
+			t.Run(name, func(t *testing.T) {
+				l.Init(nn.Shape{seqSteps, seqFeatures})
+				checkInputGradients(t, l, randomMatrix(2, seqSteps*seqFeatures))
+			})
+		}
+	}
+}
+
+func TestRecurrent_InitialState(t *testing.T) {
+	for name, l := range recurrentLayers(false, false) {
+		l.Init(nn.Shape{seqSteps, seqFeatures})
+		input := randomMatrix(2, seqSteps*seqFeatures)
+		zeros := l.Forward(input)
+		states := []*nn.Matrix{randomMatrix(2, 5)}
+		if name == "LSTM" {
+			states = append(states, randomMatrix(2, 5))
+		}
+		l.SetInitialState(states...)
+		if l.Forward(input).Eq(zeros).All() {
+			t.Errorf("%s ignored its initial state", name)
+		}
+		l.Backward(input, randomMatrix(2, 5))
+		if grads := l.InitialStateGradients(); len(grads) != len(states) || grads[0].Rows() != 2 {
+			t.Errorf("%s expected %d initial state gradients", name, len(states))
+		}
+		l.SetInitialState()
+		if !l.Forward(input).Eq(zeros).All() {
+			t.Errorf("%s did not reset to a zero initial state", name)
+		}
+	}
+}
+
+func TestRecurrent_Truncation(t *testing.T) {
+	for name, l := range recurrentLayers(false, false) {
+		l.Init(nn.Shape{seqSteps, seqFeatures})
+		l.SetTruncation(2)
+		input := randomMatrix(1, seqSteps*seqFeatures)
+		grads := l.Backward(input, randomMatrix(1, 5))
+		early := grads.SliceCols(0, 2*seqFeatures)
+		late := grads.SliceCols(2*seqFeatures, seqSteps*seqFeatures)
+		if !early.Eq(nn.NewMatrixLike(early)).All() || late.Eq(nn.NewMatrixLike(late)).All() {
+			t.Errorf("%s truncated gradients should only reach the last 2 timesteps", name)
+		}
+	}
+}
+
+func TestRecurrent_Learns(t *testing.T) {
+	for name, l := range recurrentLayers(false, false) {
+		l.Init(nn.Shape{seqSteps, seqFeatures})
+		input := randomMatrix(4, seqSteps*seqFeatures)
+		target := randomMatrix(4, 5).Multn(0.5)
+		loss := func() float32 {
+			diff := l.Forward(input).Sub(target)
+			return diff.Copy().Mult(diff).Sum()
+		}
+		before := loss()
+		for i := 0; i < 50; i++ {
+			l.Backward(input, l.Forward(input).Sub(target).Multn(2))
+			descend(l, 0.01)
+		}
+		if after := loss(); after >= before {
+			t.Errorf("%s loss did not decrease, %.4f -> %.4f", name, before, after)
+		}
+	}
+}
+
+func TestOrthogonal(t *testing.T) {
+	for _, shape := range [][2]int{{4, 4}, {3, 6}, {6, 3}} {
+		m := nn.NewMatrix(shape[0], shape[1])
+		initializers.NewOrthogonalInitializer(1).InitMatrix(m, nil)
+		gram := m.Product(m.T())
+		if shape[0] > shape[1] {
+			gram = m.T().Product(m)
+		}
+		for i := 0; i < gram.Rows(); i++ {
+			for j := 0; j < gram.Cols(); j++ {
+				want := float32(0)
+				if i == j {
+					want = 1
+				}
+				if d := gram.Get(i, j) - want; d > 1e-4 || d < -1e-4 {
+					t.Fatalf("%v matrix is not orthogonal, gram(%d, %d) = %.5f", shape, i, j, gram.Get(i, j))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package test
+
+import (
+	math "github.com/chewxy/math32"
+	"math/rand"
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/constraints"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"nn-go/nn/loss"
+	"nn-go/nn/regularizers"
+	"testing"
+)
+
+func TestRegularizers_Gradients(t *testing.T) {
+	names := []string{"L1", "L2", "L1L2", "Orthogonal"}
+	cases := []nn.Regularizer{
+		regularizers.NewL1Regularizer(0.1),
+		regularizers.NewL2Regularizer(0.1),
+		regularizers.NewL1L2Regularizer(0.1, 0.2),
+		regularizers.NewOrthogonalRegularizer(0.1),
+	}
+	for c, r := range cases {
+		m := randomMatrix(4, 3)
+		analytic := r.Gradient(m)
+		const eps = 1e-3
+		for i := 0; i < m.Rows(); i++ {
+			for j := 0; j < m.Cols(); j++ {
+				orig := m.Get(i, j)
+				m.Set(i, j, orig+eps)
+				plus := r.Penalty(m)
+				m.Set(i, j, orig-eps)
+				minus := r.Penalty(m)
+				m.Set(i, j, orig)
+				numeric := (plus - minus) / (2 * eps)
+				if math.Abs(numeric-analytic.Get(i, j)) > 1e-2*math.Max(1, math.Abs(numeric)) {
+					t.Errorf("%s gradient at (%d, %d) is %.5f, numerically %.5f", names[c], i, j, analytic.Get(i, j), numeric)
+				}
+			}
+		}
+	}
+	if got := regularizers.NewL1L2Regularizer(1, 2).Penalty(nn.NewMatrixFromArray([][]float32{{1, -2}})); got != 13 {
+		t.Errorf("L1L2 penalty should be 3 + 2*5 = 13, got %v", got)
+	}
+	orthonormal := nn.NewMatrixFromArray([][]float32{{0, 1}, {1, 0}})
+	if got := regularizers.NewOrthogonalRegularizer(1).Penalty(orthonormal); got != 0 {
+		t.Errorf("Orthonormal columns should have no penalty, got %v", got)
+	}
+}
+
+func TestDense_RegularizedGradients(t *testing.T) {
+	// The bias starts at 0.1, away from the kink of its L1 penalty at 0
+	dense := layers.NewDenseLayer(3, true, activations.Tanh, newSeededGlorot(1), initializers.NewConstInitializer(0.1)).
+		WithKernelRegularizer(regularizers.NewL2Regularizer(0.1)).
+		WithBiasRegularizer(regularizers.NewL1Regularizer(0.1)).
+		WithActivityRegularizer(regularizers.NewL1L2Regularizer(0.05, 0.1))
+	dense.Init(nn.Shape{4})
+	// Its own source, so the values don't depend on what other tests took from rng
+	source := rand.New(rand.NewSource(2))
+	input := randomMatrixFrom(source, 2, 4)
+	upstream := randomMatrixFrom(source, 2, 3)
+	objective := func() float32 {
+		output := dense.Forward(input)
+		return weightedSum(output, upstream) + dense.Penalty(input, output)
+	}
+	gradInput := dense.Backward(input, upstream)
+	const eps = 1e-3
+	// A step of eps in any value moves each output by less than eps, so no central difference
+	// crosses the kink of the L1 activity penalty
+	output := dense.Forward(input)
+	for i := 0; i < output.Rows(); i++ {
+		for j := 0; j < output.Cols(); j++ {
+			if math.Abs(output.Get(i, j)) <= eps {
+				t.Fatalf("Output at (%d, %d) is %.5f, too close to 0 to check the L1 activity gradient", i, j, output.Get(i, j))
+			}
+		}
+	}
+	check := func(name string, m *nn.Matrix, grads *nn.Matrix) {
+		for i := 0; i < m.Rows(); i++ {
+			for j := 0; j < m.Cols(); j++ {
+				orig := m.Get(i, j)
+				m.Set(i, j, orig+eps)
+				plus := objective()
+				m.Set(i, j, orig-eps)
+				minus := objective()
+				m.Set(i, j, orig)
+				numeric := (plus - minus) / (2 * eps)
+				if math.Abs(numeric-grads.Get(i, j)) > 1e-2*math.Max(1, math.Abs(numeric)) {
+					t.Fatalf("%s gradient at (%d, %d) is %.5f, numerically %.5f", name, i, j, grads.Get(i, j), numeric)
+				}
+			}
+		}
+	}
+	check("input", input, gradInput)
+	for _, p := range dense.Params() {
+		check(p.Name, p.Value, p.Grad)
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	columns := func() *nn.Matrix {
+		// Columns of norm 5, 0.5 and 0
+		return nn.NewMatrixFromArray([][]float32{{3, 0.3, 0}, {-4, -0.4, 0}})
+	}
+	norms := func(m *nn.Matrix) []float32 {
+		var out []float32
+		for j := 0; j < m.Cols(); j++ {
+			out = append(out, math.Hypot(m.Get(0, j), m.Get(1, j)))
+		}
+		return out
+	}
+	cases := []struct {
+		name       string
+		constraint nn.Constraint
+		want       []float32
+	}{
+		{"MaxNorm", constraints.NewMaxNormConstraint(2), []float32{2, 0.5, 0}},
+		{"UnitNorm", constraints.UnitNorm{}, []float32{1, 1, 0}},
+		{"MinMaxNorm", constraints.NewMinMaxNormConstraint(1, 2, 1), []float32{2, 1, 0}},
+		{"MinMaxNorm rate", constraints.NewMinMaxNormConstraint(1, 2, 0.5), []float32{3.5, 0.75, 0}},
+	}
+	for _, c := range cases {
+		m := columns()
+		c.constraint.Apply(m)
+		for j, norm := range norms(m) {
+			if math.Abs(norm-c.want[j]) > 1e-4 {
+				t.Errorf("%s column norms should be %v, got %v", c.name, c.want, norms(m))
+				break
+			}
+		}
+		// The direction of each column is kept
+		if m.Get(0, 0)/m.Get(1, 0) != -0.75 {
+			t.Errorf("%s should only rescale columns, got %v", c.name, m)
+		}
+	}
+	m := columns()
+	constraints.NonNeg{}.Apply(m)
+	if !m.Eq(nn.NewMatrixFromArray([][]float32{{3, 0.3, 0}, {0, 0, 0}})).All() {
+		t.Errorf("NonNeg should zero the negative values, got %v", m)
+	}
+}
+
+func TestTrain_RegularizedLoss(t *testing.T) {
+	regularizer := regularizers.NewL2Regularizer(0.5)
+	dense := layers.NewDenseLayer(3, true, activations.Linear, initializers.Glorot{}, initializers.Zero{}).
+		WithKernelRegularizer(regularizer).
+		WithKernelConstraint(constraints.NewMaxNormConstraint(0.1))
+	model := nn.NewModel(nn.Shape{2}, &loss.CategoricalCrossEntropy{}, &fixedLr{0.5})
+	model.AddLayer(dense).AddLayer(layers.NewSoftmaxLayer(3))
+	model.Init()
+	data := nn.TrainTestSet{Train: blobs(9), Test: blobs(3)}
+	activations := model.Forward(data.Train.Instances)
+	if got, want := model.Penalty(activations), regularizer.Penalty(dense.Params()[0].Value); got != want {
+		t.Errorf("Model penalty should be the kernel's %v, got %v", want, got)
+	}
+	model.Train(nn.NewTrainArgs(&data, nil, 2, 3, false))
+	kernel := dense.Params()[0].Value
+	for j := 0; j < kernel.Cols(); j++ {
+		if norm := math.Hypot(kernel.Get(0, j), kernel.Get(1, j)); norm > 0.1+1e-5 {
+			t.Errorf("Kernel columns should be constrained to norm 0.1, got %v", norm)
+		}
+	}
+}
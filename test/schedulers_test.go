@@ -0,0 +1,141 @@
+package test
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"nn-go/nn/loss"
+	"nn-go/nn/schedulers"
+	"testing"
+)
+
+// scheduleLrs the learning rate set by scheduler at each of steps, starting from a learning rate of 1
+func scheduleLrs(scheduler nn.Scheduler, steps []int) []float32 {
+	optimizer := &fixedLr{1}
+	var out []float32
+	for _, step := range steps {
+		scheduler.Step(optimizer, step, nn.History{})
+		out = append(out, optimizer.Lr())
+	}
+	return out
+}
+
+func TestSchedulers(t *testing.T) {
+	cases := []struct {
+		name      string
+		scheduler nn.Scheduler
+		steps     []int
+		want      []float32
+	}{
+		{"StepDecay", schedulers.NewStepDecay(2, 0.5, nn.PerEpoch),
+			[]int{0, 1, 2, 3, 4, 5}, []float32{1, 1, 0.5, 0.5, 0.25, 0.25}},
+		{"ExponentialDecay", schedulers.NewExponentialDecay(0.5, 2, nn.PerBatch),
+			[]int{0, 1, 2, 4}, []float32{1, 1 / math.Sqrt(2), 0.5, 0.25}},
+		// Periods of 4 then 8, restarting at 4 and 12
+		{"CosineAnnealing", schedulers.NewCosineAnnealing(4, 2, 0, nn.PerEpoch),
+			[]int{0, 2, 3, 4, 8, 12}, []float32{1, 0.5, (1 + math.Cos(math.Pi*3/4)) / 2, 1, 0.5, 1}},
+		{"CosineAnnealingMinLr", schedulers.NewCosineAnnealing(4, 1, 0.2, nn.PerEpoch),
+			[]int{0, 2, 4}, []float32{1, 0.6, 1}},
+		// Up from 1/25 to 1 over steps 0 to 3, then down to 1/25e4 at step 10
+		{"OneCycle", schedulers.NewOneCycle(1, 11, 0.3, 25, 1e4, nn.PerBatch),
+			[]int{0, 3, 10, 20}, []float32{0.04, 1, 4e-6, 4e-6}},
+		{"LinearWarmup", schedulers.NewLinearWarmup(4, 0.25, nil, nn.PerBatch),
+			[]int{0, 2, 4, 10}, []float32{0.25, 0.625, 1, 1}},
+		{"LinearWarmupThenStepDecay", schedulers.NewLinearWarmup(2, 0.5, schedulers.NewStepDecay(2, 0.5, nn.PerBatch), nn.PerBatch),
+			[]int{0, 1, 2, 3, 4, 6}, []float32{0.5, 0.75, 1, 1, 0.5, 0.25}},
+	}
+	for _, c := range cases {
+		got := scheduleLrs(c.scheduler, c.steps)
+		for i := range got {
+			if math.Abs(got[i]-c.want[i]) > 1e-6 {
+				t.Errorf("%s at step %d should be %.6f, got %.6f", c.name, c.steps[i], c.want[i], got[i])
+			}
+		}
+	}
+}
+
+func TestOneCycle_Shape(t *testing.T) {
+	var steps []int
+	for i := 0; i < 100; i++ {
+		steps = append(steps, i)
+	}
+	lrs := scheduleLrs(schedulers.NewOneCycle(0.1, 100, 0.3, 25, 1e4, nn.PerBatch), steps)
+	for i := 1; i < len(lrs); i++ {
+		if rising := i <= 30; rising != (lrs[i] > lrs[i-1]) {
+			t.Fatalf("One cycle should rise until step 30 then fall, step %d went from %.5f to %.5f", i, lrs[i-1], lrs[i])
+		}
+	}
+}
+
+// stillLr an optimizer that never changes the weights, so the loss stays flat
+type stillLr struct {
+	fixedLr
+}
+
+func (s *stillLr) Step([]*nn.Param) {}
+
+// countingScheduler records the steps it is called with
+type countingScheduler struct {
+	interval nn.ScheduleInterval
+	steps    []int
+}
+
+func (c *countingScheduler) Interval() nn.ScheduleInterval {
+	return c.interval
+}
+
+func (c *countingScheduler) Step(_ nn.Optimizer, step int, _ nn.History) {
+	c.steps = append(c.steps, step)
+}
+
+// trainWithScheduler train a small classifier with optimizer for epochs epochs of 3 batches
+func trainWithScheduler(optimizer nn.Optimizer, scheduler nn.Scheduler, epochs int) {
+	model := nn.NewModel(nn.Shape{2}, &loss.CategoricalCrossEntropy{}, optimizer)
+	model.
+		AddLayer(layers.NewDenseLayer(3, true, activations.Linear, initializers.Glorot{}, initializers.Zero{})).
+		AddLayer(layers.NewSoftmaxLayer(3))
+	model.Init()
+	data := nn.TrainTestSet{Train: blobs(9), Test: blobs(3)}
+	model.Train(nn.NewTrainArgs(&data, nil, epochs, 3, false).WithScheduler(scheduler))
+}
+
+func TestScheduler_Intervals(t *testing.T) {
+	perBatch := &countingScheduler{interval: nn.PerBatch}
+	trainWithScheduler(&fixedLr{0.1}, perBatch, 2)
+	if want := []int{0, 1, 2, 3, 4, 5, 6}; len(perBatch.steps) != len(want) || perBatch.steps[6] != 6 {
+		t.Errorf("Per batch scheduler should step %v, got %v", want, perBatch.steps)
+	}
+	perEpoch := &countingScheduler{interval: nn.PerEpoch}
+	trainWithScheduler(&fixedLr{0.1}, perEpoch, 2)
+	if want := []int{0, 1, 2}; len(perEpoch.steps) != len(want) || perEpoch.steps[2] != 2 {
+		t.Errorf("Per epoch scheduler should step %v, got %v", want, perEpoch.steps)
+	}
+}
+
+func TestReduceLROnPlateau(t *testing.T) {
+	// The loss never improves, so the lr halves every 2 epochs after the first
+	optimizer := &stillLr{fixedLr{1}}
+	trainWithScheduler(optimizer, schedulers.NewReduceLROnPlateau(0.5, 2, 1e-4, 0, 0), 6)
+	if optimizer.Lr() != 0.25 {
+		t.Errorf("Learning rate should be reduced twice to 0.25, got %v", optimizer.Lr())
+	}
+	optimizer = &stillLr{fixedLr{1}}
+	trainWithScheduler(optimizer, schedulers.NewReduceLROnPlateau(0.5, 1, 1e-4, 0, 0.3), 6)
+	if optimizer.Lr() != 0.3 {
+		t.Errorf("Learning rate should stop at the minimum 0.3, got %v", optimizer.Lr())
+	}
+	// Cooldown skips 2 epochs after each reduction, reducing after epochs 2 and 5
+	optimizer = &stillLr{fixedLr{1}}
+	trainWithScheduler(optimizer, schedulers.NewReduceLROnPlateau(0.5, 1, 1e-4, 2, 0), 6)
+	if optimizer.Lr() != 0.25 {
+		t.Errorf("Learning rate should be reduced twice with cooldown to 0.25, got %v", optimizer.Lr())
+	}
+	// A falling loss is never reduced
+	sgd := &fixedLr{0.1}
+	trainWithScheduler(sgd, schedulers.NewReduceLROnPlateau(0.5, 1, 1e-4, 0, 0), 6)
+	if sgd.Lr() != 0.1 {
+		t.Errorf("Learning rate should stay at 0.1 while the loss improves, got %v", sgd.Lr())
+	}
+}
@@ -0,0 +1,70 @@
+package test
+
+import (
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"nn-go/nn/loss"
+	"nn-go/nn/optimisers"
+	"testing"
+)
+
+func TestModel_Shapes(t *testing.T) {
+	model := nn.NewModel(nn.Shape{4, 3}, &loss.CategoricalCrossEntropy{}, optimisers.NewAdamOptimizer(0.001, 0.9, 0.999, 1e-8, false))
+	model.
+		AddLayer(layers.NewLSTMLayer(5, true, false, true, initializers.Glorot{}, initializers.NewOrthogonalInitializer(1), initializers.Zero{})).
+		AddLayer(layers.NewDenseLayer(2, true, activations.Linear, initializers.Glorot{}, initializers.Zero{})).
+		AddLayer(layers.NewPermuteLayer(1, 0)).
+		AddLayer(layers.NewFlattenLayer()).
+		AddLayer(layers.NewRepeatVectorLayer(3)).
+		AddLayer(layers.NewReshapeLayer(nn.Shape{-1, 4})).
+		AddLayer(layers.NewSoftmaxLayer(4))
+	model.Init()
+	want := []nn.Shape{{4, 3}, {4, 5}, {4, 2}, {2, 4}, {8}, {3, 8}, {6, 4}, {6, 4}}
+	shapes := model.Shapes()
+	if len(shapes) != len(want) {
+		t.Fatalf("Expected %d shapes, got %v", len(want), shapes)
+	}
+	for i := range want {
+		if !shapes[i].Equal(want[i]) {
+			t.Errorf("Shape %d is %v, want %v", i, shapes[i], want[i])
+		}
+	}
+	if out := model.Predict(randomMatrix(2, 12)); out.Cols() != 24 {
+		t.Errorf("Expected 24 outputs, got %d", out.Cols())
+	}
+}
+
+func TestPermute(t *testing.T) {
+	l := layers.NewPermuteLayer(1, 0)
+	l.Init(nn.Shape{2, 3})
+	input := nn.NewMatrixFromArray([][]float32{{1, 2, 3, 4, 5, 6}})
+	want := nn.NewMatrixFromArray([][]float32{{1, 4, 2, 5, 3, 6}})
+	if !l.Forward(input).Eq(want).All() {
+		t.Fatal("Permute did not transpose the sample")
+	}
+	if !l.Backward(input, want).Eq(input).All() {
+		t.Fatal("Permute backward did not undo the transpose")
+	}
+	checkInputGradients(t, l, randomMatrix(2, 6))
+}
+
+func TestRepeatVector(t *testing.T) {
+	l := layers.NewRepeatVectorLayer(3)
+	l.Init(nn.Shape{2})
+	input := nn.NewMatrixFromArray([][]float32{{1, 2}})
+	want := nn.NewMatrixFromArray([][]float32{{1, 2, 1, 2, 1, 2}})
+	if !l.Forward(input).Eq(want).All() {
+		t.Fatal("RepeatVector did not repeat the input")
+	}
+	checkInputGradients(t, l, randomMatrix(2, 2))
+}
+
+func TestDense_Sequence(t *testing.T) {
+	l := layers.NewDenseLayer(2, true, activations.Linear, initializers.Glorot{}, initializers.NewConstInitializer(0.1))
+	if output := l.Init(nn.Shape{3, 4}); !output.Equal(nn.Shape{3, 2}) {
+		t.Fatalf("Expected output shape (3, 2), got %v", output)
+	}
+	checkInputGradients(t, l, randomMatrix(2, 12))
+}
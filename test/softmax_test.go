@@ -0,0 +1,114 @@
+package test
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"nn-go/nn/loss"
+	"testing"
+)
+
+func oneHot(rows int, classes int) *nn.Matrix {
+	m := nn.NewMatrix(rows, classes)
+	for i := 0; i < rows; i++ {
+		m.Set(i, rng.Intn(classes), 1)
+	}
+	return m
+}
+
+// checkLossGradients compare loss.Gradient with central differences of the sum of loss.Call,
+// which is the reduced loss or, without a reduction, the sum of each sample's loss
+func checkLossGradients(t *testing.T, name string, l nn.Loss, observed *nn.Matrix, expected *nn.Matrix) {
+	t.Helper()
+	checkWeightedLossGradients(t, name, l, observed, expected, nil)
+}
+
+// checkWeightedLossGradients checkLossGradients with the loss of each sample weighted by weights
+func checkWeightedLossGradients(t *testing.T, name string, l nn.Loss, observed, expected, weights *nn.Matrix) {
+	t.Helper()
+	analytic := l.Gradient(observed, expected, weights)
+	const eps = 1e-3
+	for i := 0; i < observed.Rows(); i++ {
+		for j := 0; j < observed.Cols(); j++ {
+			orig := observed.Get(i, j)
+			observed.Set(i, j, orig+eps)
+			plus := l.Call(observed, expected, weights).Sum()
+			observed.Set(i, j, orig-eps)
+			minus := l.Call(observed, expected, weights).Sum()
+			observed.Set(i, j, orig)
+			numeric := (plus - minus) / (2 * eps)
+			if diff := math.Abs(numeric - analytic.Get(i, j)); diff > 1e-2*math.Max(1, math.Abs(numeric)) {
+				t.Fatalf("%s gradient at (%d, %d) is %.5f, numerically %.5f", name, i, j, analytic.Get(i, j), numeric)
+			}
+		}
+	}
+}
+
+func TestSoftmax_Backward(t *testing.T) {
+	l := layers.NewSoftmaxLayer(4)
+	l.Init(nn.Shape{4})
+	checkInputGradients(t, l, randomMatrix(3, 4))
+	l = layers.NewSoftmaxLayer(3)
+	l.Init(nn.Shape{2, 3})
+	checkInputGradients(t, l, randomMatrix(2, 6))
+}
+
+func TestCategoricalCrossEntropy_Gradient(t *testing.T) {
+	probs := randomMatrix(3, 4).Softmax()
+	checkLossGradients(t, "CategoricalCrossEntropy", &loss.CategoricalCrossEntropy{}, probs, oneHot(3, 4))
+}
+
+func TestSoftmaxCrossEntropy(t *testing.T) {
+	logits := randomMatrix(3, 4).Multn(3)
+	labels := oneHot(3, 4)
+	cce := &loss.CategoricalCrossEntropy{Reduction: nn.ReduceNone}
+	fused := cce.FuseSoftmax()
+	want := cce.Call(logits.Softmax(), labels, nil)
+	got := fused.Call(logits, labels, nil)
+	for i := 0; i < 3; i++ {
+		if math.Abs(got.Get(i, 0)-want.Get(i, 0)) > 1e-3 {
+			t.Errorf("Fused loss %.4f does not match %.4f", got.Get(i, 0), want.Get(i, 0))
+		}
+	}
+	checkLossGradients(t, "SoftmaxCrossEntropy", fused, logits, labels)
+
+	huge := nn.NewMatrixFromArray([][]float32{{1000, -1000, 0}})
+	if l := fused.Call(huge, nn.NewMatrixFromArray([][]float32{{0, 1, 0}}), nil).Get(0, 0); math.IsInf(l, 0) || math.IsNaN(l) || l < 1999 {
+		t.Errorf("Fused loss should be stable for large logits, got %.2f", l)
+	}
+}
+
+// blobs n samples of 2 features around a different centre for each of 3 classes
+func blobs(n int) nn.DataSet {
+	centres := [][]float32{{-1, -1}, {1, -1}, {0, 1}}
+	x, y := nn.NewMatrix(n, 2), nn.NewMatrix(n, 3)
+	for i := 0; i < n; i++ {
+		c := i % 3
+		x.Set(i, 0, centres[c][0]+0.3*(rng.Float32()-0.5))
+		x.Set(i, 1, centres[c][1]+0.3*(rng.Float32()-0.5))
+		y.Set(i, c, 1)
+	}
+	return nn.DataSet{Instances: x, Labels: y}
+}
+
+func TestModel_SoftmaxClassifier(t *testing.T) {
+	model := nn.NewModel(nn.Shape{2}, &loss.CategoricalCrossEntropy{}, &fixedLr{0.1})
+	model.
+		AddLayer(layers.NewDenseLayer(8, true, activations.Tanh, initializers.Glorot{}, initializers.Zero{})).
+		AddLayer(layers.NewDenseLayer(3, true, activations.Linear, initializers.Glorot{}, initializers.Zero{})).
+		AddLayer(layers.NewSoftmaxLayer(3))
+	model.Init()
+	data := nn.TrainTestSet{Train: blobs(30), Test: blobs(9)}
+	before := model.Loss(model.Predict(data.Test.Instances), data.Test.Labels).Mean()
+	model.Train(nn.NewTrainArgs(&data, nil, 20, 10, true))
+	predictions := model.Predict(data.Test.Instances)
+	after := model.Loss(predictions, data.Test.Labels).Mean()
+	if after >= before/2 {
+		t.Errorf("Test loss should fall by at least half, %.4f -> %.4f", before, after)
+	}
+	if accuracy := predictions.ArgMax().Eq(data.Test.Labels.ArgMax()).Mean(); accuracy < 0.99 {
+		t.Errorf("Expected the blobs to be separated, accuracy %.2f", accuracy)
+	}
+}
@@ -0,0 +1,83 @@
+package test
+
+import (
+	math "github.com/chewxy/math32"
+	"nn-go/nn"
+	"nn-go/nn/activations"
+	"nn-go/nn/initializers"
+	"nn-go/nn/layers"
+	"nn-go/nn/loss"
+	"testing"
+)
+
+func TestLosses_Weights(t *testing.T) {
+	observed := nn.NewMatrixFromArray([][]float32{{1, 2}, {0, 0}})
+	expected := nn.NewMatrixFromArray([][]float32{{0, 4}, {0, 1}})
+	weights := nn.NewMatrixFromArray([][]float32{{2}, {0}})
+	// Per-sample losses are 2.5 and 0.5
+	if got := (&loss.MeanSquaredError{}).Call(observed, expected, weights).Get(0, 0); got != 2.5 {
+		t.Errorf("Weighted mean should be 2.5, got %v", got)
+	}
+	if got := (&loss.MeanSquaredError{Reduction: nn.ReduceSum}).Call(observed, expected, weights).Get(0, 0); got != 5 {
+		t.Errorf("Weighted sum should be 5, got %v", got)
+	}
+	none := (&loss.MeanSquaredError{Reduction: nn.ReduceNone}).Call(observed, expected, weights)
+	if none.Get(0, 0) != 5 || none.Get(1, 0) != 0 {
+		t.Errorf("Unreduced losses should be weighted, got %v", none)
+	}
+	grads := (&loss.MeanSquaredError{}).Gradient(observed, expected, weights)
+	if grads.Get(1, 0) != 0 || grads.Get(1, 1) != 0 {
+		t.Errorf("A zero weight should zero the gradient, got %v", grads)
+	}
+}
+
+func TestLosses_WeightedGradients(t *testing.T) {
+	weights := nn.NewMatrixFromArray([][]float32{{0.5}, {2}, {0}, {1}})
+	sparse := nn.NewMatrixFromArray([][]float32{{2}, {0}, {1}, {2}})
+	for _, r := range []nn.Reduction{nn.ReduceMean, nn.ReduceSum, nn.ReduceNone} {
+		checkWeightedLossGradients(t, "MeanSquaredError", &loss.MeanSquaredError{Reduction: r},
+			randomMatrix(4, 3), randomMatrix(4, 3), weights)
+		checkWeightedLossGradients(t, "SoftmaxCrossEntropy", &loss.SoftmaxCrossEntropy{Reduction: r},
+			randomMatrix(4, 3).Multn(3), oneHot(4, 3), weights)
+		checkWeightedLossGradients(t, "SparseSoftmaxCrossEntropy", &loss.SparseSoftmaxCrossEntropy{Reduction: r},
+			randomMatrix(4, 3).Multn(3), sparse, weights)
+	}
+}
+
+func TestBalancedClassWeights(t *testing.T) {
+	labels := nn.NewMatrixFromArray([][]float32{{1, 0, 0}, {1, 0, 0}, {1, 0, 0}, {0, 1, 0}})
+	want := []float32{4. / 9, 4. / 3, 1}
+	for c, w := range nn.BalancedClassWeights(labels) {
+		if math.Abs(w-want[c]) > 1e-6 {
+			t.Errorf("Class %d should have weight %.4f, got %.4f", c, want[c], w)
+		}
+	}
+	binary := nn.NewMatrixFromArray([][]float32{{0}, {1}, {1}, {1}})
+	if got := nn.BalancedClassWeights(binary); len(got) != 2 || got[0] != 2 || math.Abs(got[1]-2./3) > 1e-6 {
+		t.Errorf("Binary labels should have weights [2 0.6667], got %v", got)
+	}
+}
+
+func TestClassSampleWeights(t *testing.T) {
+	labels := nn.NewMatrixFromArray([][]float32{{2}, {0}, {1}})
+	samples := nn.NewMatrixFromArray([][]float32{{1}, {2}, {3}})
+	got := nn.ClassSampleWeights(labels, []float32{0.5, 1, 4}, samples)
+	want := nn.NewMatrixFromArray([][]float32{{4}, {1}, {3}})
+	if !got.Eq(want).All() {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestModel_ZeroSampleWeights(t *testing.T) {
+	model := nn.NewModel(nn.Shape{2}, &loss.CategoricalCrossEntropy{}, &fixedLr{0.1})
+	model.
+		AddLayer(layers.NewDenseLayer(3, true, activations.Linear, initializers.Glorot{}, initializers.Zero{})).
+		AddLayer(layers.NewSoftmaxLayer(3))
+	model.Init()
+	data := nn.TrainTestSet{Train: blobs(9), Test: blobs(3)}
+	before := model.Predict(data.Test.Instances)
+	model.Train(nn.NewTrainArgs(&data, nil, 2, 3, true).WithSampleWeights(nn.NewMatrix(9, 1)))
+	if after := model.Predict(data.Test.Instances); !after.Eq(before).All() {
+		t.Errorf("Training with zero weights should not change the model")
+	}
+}